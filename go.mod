@@ -0,0 +1,10 @@
+module github.com/ian-kent/gofigure
+
+go 1.16
+
+require (
+	github.com/BurntSushi/toml v1.2.1
+	github.com/ian-kent/envconf v0.0.0-20141026121121-c19809918c02
+	github.com/smartystreets/goconvey v1.6.4
+	gopkg.in/yaml.v2 v2.4.0
+)
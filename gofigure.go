@@ -4,6 +4,7 @@
 package gofigure
 
 import (
+	"encoding"
 	"errors"
 	"fmt"
 	"log"
@@ -38,40 +39,123 @@ func printf(message string, args ...interface{}) {
 }
 
 /* TODO
- * - Add file/http sources
- *   - Add "decoders", e.g. json/env/xml
  * - Default value (if gofigure is func()*StructType)
- * - Ignore lowercased "unexported" fields?
  */
 
 // gofiguration represents a parsed struct
 type gofiguration struct {
+	loader   *Loader
 	order    []string
 	params   map[string]map[string]string
-	fields   map[string]*gofiguritem
+	fields   []*gofiguritem
 	flagged  bool
 	parent   *gofiguration
 	children []*gofiguration
+	item     *gofiguritem
 	s        interface{}
 }
 
 func (gfg *gofiguration) printf(message string, args ...interface{}) {
-	printf(message, args...)
+	gfg.loader.printf(message, args...)
 }
 
 // gofiguritem represents a single struct field
+//
+// For pointer fields, goPtr is the field and goValue is the value
+// it points to, which is only assigned to the field if it's set.
+//
+// Values in a map or slice of structs are represented by items in elems,
+// with the map key or index as the field and the map or slice as the container.
+//
+// Fields flattened from embedded structs have the path of the
+// embedded struct in embedded, e.g. "CommonConfig."
+//
+// set is true if a source or the default tag provided a value,
+// and defaulted is true if it was the default tag.
 type gofiguritem struct {
-	keys    map[string]string
-	field   string
-	goField reflect.StructField
-	goValue reflect.Value
-	inner   *gofiguration
+	keys      map[string]string
+	field     string
+	embedded  string
+	goField   reflect.StructField
+	goValue   reflect.Value
+	goPtr     reflect.Value
+	set       bool
+	defaulted bool
+	inner     *gofiguration
+	parent    *gofiguration
+	container *gofiguritem
+	elems     []*gofiguritem
+}
+
+// key returns the key used to look up the item in a source
+func (gfi *gofiguritem) key(source string) string {
+	kn := gfi.field
+	if k, ok := gfi.keys[source]; ok {
+		// ignore tag options, e.g. json:"name,omitempty"
+		if i := strings.Index(k, ","); i > -1 {
+			k = k[:i]
+		}
+		if len(k) > 0 {
+			kn = k
+		}
+	}
+
+	if gfi.container != nil {
+		return gfi.container.key(source) + sources.KeySeparator + kn
+	}
+	if gfi.parent != nil && gfi.parent.item != nil {
+		if gfi.parent.loader.isNested(source) || gfi.parent.item.inElement() {
+			return gfi.parent.item.key(source) + sources.KeySeparator + kn
+		}
+		if prefix := gfi.parent.item.prefix(); len(prefix) > 0 {
+			return prefix + sources.KeySeparator + kn
+		}
+	}
+
+	return kn
+}
+
+// source returns the source used by the item's loader
+func (gfi *gofiguritem) source(name string) sources.Source {
+	return gfi.parent.loader.sources[name]
+}
+
+func (gfi *gofiguritem) printf(message string, args ...interface{}) {
+	gfi.parent.printf(message, args...)
+}
+
+// prefix returns the prefix for fields in a nested struct, from
+// the prefix tag of the struct and any structs it's nested in
+func (gfi *gofiguritem) prefix() string {
+	var parent string
+	if gfi.parent != nil && gfi.parent.item != nil {
+		parent = gfi.parent.item.prefix()
+	}
+	p := gfi.keys["prefix"]
+	if len(parent) > 0 && len(p) > 0 {
+		return parent + sources.KeySeparator + p
+	}
+	return parent + p
 }
 
 // Sources contains a map of struct field tag names to source implementation
-var Sources = map[string]sources.Source{
-	"env":  &sources.Environment{},
-	"flag": &sources.CommandLine{},
+var Sources = newSources()
+
+// newSources returns new instances of the default sources
+func newSources() map[string]sources.Source {
+	return map[string]sources.Source{
+		"env":        &sources.Environment{},
+		"flag":       &sources.CommandLine{},
+		"json":       &sources.JSONFile{},
+		"yaml":       &sources.YAMLFile{},
+		"toml":       &sources.TOMLFile{},
+		"dotenv":     &sources.DotEnv{},
+		"ini":        &sources.INIFile{},
+		"properties": &sources.PropertiesFile{},
+		"http":       &sources.HTTP{},
+		"file":       &sources.File{},
+		"dir":        &sources.Dir{},
+	}
 }
 
 // DefaultOrder sets the default order used
@@ -92,7 +176,7 @@ var ErrUnsupportedFieldType = errors.New("Unsupported field type")
 //
 // It returns ErrUnsupportedType if s is not a struct or a
 // pointer to a struct.
-func parseStruct(s interface{}) (*gofiguration, error) {
+func (l *Loader) parseStruct(s interface{}) (*gofiguration, error) {
 	var v reflect.Value
 	if reflect.TypeOf(s) != reflect.TypeOf(v) {
 		v = reflect.ValueOf(s)
@@ -111,9 +195,9 @@ func parseStruct(s interface{}) (*gofiguration, error) {
 	t := v.Type()
 
 	gfg := &gofiguration{
+		loader: l,
 		params: make(map[string]map[string]string),
-		order:  DefaultOrder,
-		fields: make(map[string]*gofiguritem),
+		order:  l.order,
 		s:      s,
 	}
 
@@ -122,7 +206,10 @@ func parseStruct(s interface{}) (*gofiguration, error) {
 		return nil, err
 	}
 
-	gfg.parseFields(v, t)
+	err = gfg.parseFields(v, t, "")
+	if err != nil {
+		return nil, err
+	}
 
 	return gfg, nil
 }
@@ -173,7 +260,7 @@ func getStructTags(tag string) map[string]string {
 	return m
 }
 
-var argRe = regexp.MustCompile("([a-z]+)([A-Z][a-z]+)")
+var argRe = regexp.MustCompile("^([a-z]+)([A-Z][a-zA-Z0-9]*)$")
 
 func (gfg *gofiguration) parseGofigureField(t reflect.Type) error {
 	gf, ok := t.FieldByName("gofigure")
@@ -183,7 +270,7 @@ func (gfg *gofiguration) parseGofigureField(t reflect.Type) error {
 			if name == "order" {
 				oParts := strings.Split(value, ",")
 				for _, p := range oParts {
-					if _, ok := Sources[p]; !ok {
+					if _, ok := gfg.loader.sources[p]; !ok {
 						return ErrInvalidOrder
 					}
 				}
@@ -193,6 +280,8 @@ func (gfg *gofiguration) parseGofigureField(t reflect.Type) error {
 			// Parse orderKey:"value" tags, e.g.
 			// envPrefix, which gets split into
 			//   gfg.params["env"]["prefix"] = "value"
+			// or envFileSuffix, which gets split into
+			//   gfg.params["env"]["fileSuffix"] = "value"
 			// gfg.params["env"] is then passed to
 			// source registered with that key
 			match := argRe.FindStringSubmatch(name)
@@ -200,14 +289,18 @@ func (gfg *gofiguration) parseGofigureField(t reflect.Type) error {
 				if _, ok := gfg.params[match[1]]; !ok {
 					gfg.params[match[1]] = make(map[string]string)
 				}
-				gfg.params[match[1]][strings.ToLower(match[2])] = value
+				param := strings.ToLower(match[2][:1]) + match[2][1:]
+				gfg.params[match[1]][param] = value
 			}
 		}
 	}
 	return nil
 }
 
-func (gfg *gofiguration) parseFields(v reflect.Value, t reflect.Type) {
+// parseFields adds an item for each field. Fields of embedded
+// structs are added as if they were fields of the parent struct,
+// unless the embedded struct has an inline:"false" tag.
+func (gfg *gofiguration) parseFields(v reflect.Value, t reflect.Type, embedded string) error {
 	gfg.printf("Found %d fields", t.NumField())
 	for i := 0; i < t.NumField(); i++ {
 		f := t.Field(i).Name
@@ -216,31 +309,109 @@ func (gfg *gofiguration) parseFields(v reflect.Value, t reflect.Type) {
 			continue
 		}
 
+		keys := make(map[string]string)
+		tag := t.Field(i).Tag
+		if len(tag) > 0 {
+			keys = getStructTags(string(tag))
+		}
+
+		if isIgnored(t.Field(i), keys) {
+			gfg.printf("Ignored field '%s'", f)
+			continue
+		}
+
+		if t.Field(i).Anonymous && t.Field(i).Type.Kind() == reflect.Struct && !isTextUnmarshaler(t.Field(i).Type) {
+			inline := true
+			if in, ok := keys["inline"]; ok {
+				var err error
+				inline, err = strconv.ParseBool(in)
+				if err != nil {
+					return fmt.Errorf("Invalid inline tag for field '%s': %s", embedded+f, err)
+				}
+			}
+			if inline {
+				gfg.printf("Flattening embedded field '%s'", f)
+				err := gfg.parseFields(v.Field(i), t.Field(i).Type, embedded+f+".")
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			// Unexported embedded structs can only be set once flattened
+			if len(t.Field(i).PkgPath) > 0 {
+				gfg.printf("Ignored field '%s'", f)
+				continue
+			}
+		}
+
 		gfg.printf("Parsed field '%s'", f)
 
 		gfi := &gofiguritem{
-			field:   f,
-			goField: t.Field(i),
-			goValue: v.Field(i),
-			keys:    make(map[string]string),
+			field:    f,
+			embedded: embedded,
+			goField:  t.Field(i),
+			goValue:  v.Field(i),
+			keys:     keys,
+			parent:   gfg,
 		}
-		tag := t.Field(i).Tag
-		if len(tag) > 0 {
-			gfi.keys = getStructTags(string(tag))
+		if gfi.goValue.Kind() == reflect.Ptr {
+			gfi.goPtr = gfi.goValue
+			if gfi.goPtr.IsNil() {
+				gfi.goValue = reflect.New(gfi.goPtr.Type().Elem()).Elem()
+			} else {
+				gfi.goValue = gfi.goPtr.Elem()
+			}
 		}
-		gfg.fields[f] = gfi
+		gfg.fields = append(gfg.fields, gfi)
 	}
+	return nil
+}
+
+// checkEmbedded returns an error if a field from an embedded
+// struct has the same key as another field for any source
+func (gfg *gofiguration) checkEmbedded() error {
+	for _, o := range gfg.order {
+		keys := make(map[string]*gofiguritem)
+		for _, gfi := range gfg.fields {
+			t := gfi.goValue.Type()
+			if (t.Kind() == reflect.Struct && !isTextUnmarshaler(t)) || isStructSlice(t) || isStructMap(t) {
+				continue
+			}
+			if gfi.ignored(o) {
+				continue
+			}
+			k := gfi.key(o)
+			other, ok := keys[k]
+			if !ok {
+				keys[k] = gfi
+				continue
+			}
+			if len(gfi.embedded) == 0 && len(other.embedded) == 0 {
+				continue
+			}
+			// Describe the embedded field first, or the later
+			// field if both are embedded
+			if len(gfi.embedded) == 0 {
+				gfi, other = other, gfi
+			}
+			if d, ok := gfg.loader.sources[o].(sources.Describer); ok {
+				k = d.Describe(k)
+			}
+			return fmt.Errorf("Embedded field '%s' collides with field '%s' (%s %s)", gfi.embedded+gfi.field, other.embedded+other.field, o, k)
+		}
+	}
+	return nil
 }
 
 func (gfg *gofiguration) cleanupSources() {
 	for _, o := range gfg.order {
-		Sources[o].Cleanup()
+		gfg.loader.sources[o].Cleanup()
 	}
 }
 
 func (gfg *gofiguration) initSources() error {
 	for _, o := range gfg.order {
-		err := Sources[o].Init(gfg.params[o])
+		err := gfg.loader.sources[o].Init(gfg.params[o])
 		if err != nil {
 			return err
 		}
@@ -249,36 +420,128 @@ func (gfg *gofiguration) initSources() error {
 }
 
 func (gfg *gofiguration) registerFields() error {
-	for _, gfi := range gfg.fields {
-		kn := gfi.field
+	err := gfg.checkEmbedded()
+	if err != nil {
+		return err
+	}
 
-		var err error
-		switch gfi.goField.Type.Kind() {
-		case reflect.Struct:
+	for _, gfi := range gfg.fields {
+		switch {
+		case gfi.goValue.Kind() == reflect.Struct && !isTextUnmarshaler(gfi.goValue.Type()):
 			gfg.printf("Registering as struct type")
-			// TODO do shit
-			sGfg, err := parseStruct(gfi.goValue)
+			sGfg, err := gfg.loader.parseStruct(gfi.goValue)
 			if err != nil {
 				return err
 			}
-			sGfg.apply(gfg)
+			// Sources are only initialised for the top level struct,
+			// so nested structs always use the parent configuration
+			sGfg.order = gfg.order
+			sGfg.params = gfg.params
+			sGfg.item = gfi
 			gfi.inner = sGfg
+			err = sGfg.apply(gfg)
+			if err != nil {
+				return err
+			}
+		case isStructSlice(gfi.goValue.Type()):
+			gfg.printf("Registering as slice of structs type")
+			err := gfi.registerStructSlice(gfg)
+			if err != nil {
+				return err
+			}
+		case isStructMap(gfi.goValue.Type()):
+			gfg.printf("Registering as map of structs type")
+			err := gfi.registerStructMap(gfg)
+			if err != nil {
+				return err
+			}
 		default:
 			gfg.printf("Registering as default type")
-			for _, o := range gfg.order {
-				if k, ok := gfi.keys[o]; ok {
-					kn = k
-				}
+			def, err := gfi.defaultValue()
+			if err != nil {
+				return err
+			}
+			// Text and time types are a single value, even if they're
+			// slices, e.g. net.IP, so they're registered as strings
+			t := gfi.goValue.Type()
+			if isTextUnmarshaler(t) || isTimeType(t) {
+				t = stringType
+			}
+			for _, o := range gfi.sourceOrder(gfg.order) {
+				kn := gfi.key(o)
 				gfg.printf("Registering '%s' for source '%s' with key '%s'", gfi.field, o, kn)
-				err = Sources[o].Register(kn, "", gfi.keys, gfi.goField.Type)
+				err := gfi.source(o).Register(kn, def, gfi.keys, t)
+				if err != nil {
+					return err
+				}
 			}
 		}
+	}
+	return nil
+}
 
-		if err != nil {
-			return err
+// defaultValue returns the default tag after checking
+// it can be converted to the field type
+func (gfi *gofiguritem) defaultValue() (string, error) {
+	def, ok := gfi.keys["default"]
+	if !ok {
+		return "", nil
+	}
+
+	var err error
+	v := reflect.New(gfi.goValue.Type()).Elem()
+	switch {
+	case isTextUnmarshaler(v.Type()):
+		err = setDefaultType(v, def, gfi.keys, gfi.printf)
+	case v.Kind() == reflect.Slice:
+		err = appendSliceType(v, splitDefault(def), gfi.keys, gfi.printf)
+	case v.Kind() == reflect.Array:
+		err = setArrayType(v, splitDefault(def), gfi.keys, gfi.printf)
+	case v.Kind() == reflect.Map:
+		var m map[string]string
+		m, err = sources.ParseMap(splitDefault(def))
+		if err == nil {
+			err = setMapType(v, m, gfi.keys, gfi.printf)
 		}
+	default:
+		err = setDefaultType(v, def, gfi.keys, gfi.printf)
 	}
-	return nil
+	if err != nil {
+		return "", fmt.Errorf("Invalid default value for field '%s': %s", gfi.field, err)
+	}
+
+	return def, nil
+}
+
+// isZero returns true if the field has its zero value,
+// or for pointer fields, if the pointer is nil
+func (gfi *gofiguritem) isZero() bool {
+	if gfi.goPtr.IsValid() {
+		return gfi.goPtr.IsNil()
+	}
+	return reflect.DeepEqual(gfi.goValue.Interface(), reflect.Zero(gfi.goValue.Type()).Interface())
+}
+
+// isNil returns true for pointer fields which haven't been set
+func (gfi *gofiguritem) isNil() bool {
+	return gfi.goPtr.IsValid() && gfi.goPtr.IsNil()
+}
+
+// assign sets a nil pointer field to point to its value,
+// if any source has provided a value
+func (gfi *gofiguritem) assign() {
+	if gfi.isNil() && gfi.set {
+		gfi.printf("Assigning pointer for field '%s'", gfi.field)
+		gfi.goPtr.Set(gfi.goValue.Addr())
+	}
+}
+
+// splitDefault splits a default tag for slice fields, e.g. default:"a,b,c"
+func splitDefault(def string) []string {
+	if len(def) == 0 {
+		return []string{}
+	}
+	return strings.Split(def, ",")
 }
 
 func numVal(i string) string {
@@ -288,124 +551,411 @@ func numVal(i string) string {
 	return i
 }
 
+// unset is passed as the default value to sources which don't
+// implement sources.LookupSource, so fields which they don't
+// provide a value for can be detected
+const unset = "\x00gofigure:unset"
+
+// lookup returns the value for key from the source,
+// and false if the source has no value for it
+func (gfi *gofiguritem) lookup(source, key string) (string, bool, error) {
+	src := gfi.source(source)
+	if ls, ok := src.(sources.LookupSource); ok {
+		return ls.Lookup(key)
+	}
+	def := unset
+	val, err := src.Get(key, &def)
+	if err != nil {
+		return "", false, err
+	}
+	return val, val != unset, nil
+}
+
 func (gfi *gofiguritem) populateDefaultType(order []string) error {
-	// FIXME could just preserve types
-	var v string
-	switch gfi.goField.Type.Kind() {
-	case reflect.Bool:
-		v = fmt.Sprintf("%t", gfi.goValue.Bool())
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		v = fmt.Sprintf("%d", gfi.goValue.Int())
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		v = fmt.Sprintf("%d", gfi.goValue.Uint())
-	case reflect.Float32, reflect.Float64:
-		v = fmt.Sprintf("%f", gfi.goValue.Float())
-	case reflect.String:
-		v = gfi.goValue.String()
+	for _, source := range order {
+		kn := gfi.key(source)
+
+		val, ok, err := gfi.lookup(source, kn)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		gfi.printf("Got value '%s' from source '%s' for key '%s'", val, source, gfi.field)
+
+		gfi.set = true
+		err = setDefaultType(gfi.goValue, val, gfi.keys, gfi.printf)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The default tag is only used if no source provided a
+	// value and the field hasn't already been set
+	if def, ok := gfi.keys["default"]; ok && !gfi.set && gfi.isZero() {
+		gfi.printf("Using default value '%s' for field '%s'", def, gfi.field)
+		gfi.set = true
+		gfi.defaulted = true
+		return setDefaultType(gfi.goValue, def, gfi.keys, gfi.printf)
 	}
 
-	var prevVal = &v
+	return nil
+}
+
+func (gfi *gofiguritem) populateSliceType(order []string) error {
+	if isStructSlice(gfi.goValue.Type()) {
+		return gfi.populateStructSliceType()
+	}
+
+	// An empty override prevents sources returning their own
+	// defaults, which are applied once all sources are checked
+	var prevVal = &[]string{}
 
 	for _, source := range order {
-		kn := gfi.field
-		if k, ok := gfi.keys[source]; ok {
-			kn = k
+		kn := gfi.key(source)
+
+		gfi.printf("Looking for field '%s' with key '%s' in source '%s'", gfi.field, kn, source)
+		val, err := gfi.source(source).GetArray(kn, prevVal)
+		if err != nil {
+			return err
 		}
 
-		val, err := Sources[source].Get(kn, prevVal)
+		// This causes duplication between array sources depending on order
+		//prevVal = &val
+
+		gfi.printf("Got value '%+v' from array source '%s' for key '%s'", val, source, gfi.field)
+
+		if len(val) > 0 {
+			gfi.set = true
+		}
+		err = appendSliceType(gfi.goValue, val, gfi.keys, gfi.printf)
+		if err == ErrUnsupportedFieldType {
+			return fmt.Errorf("Unsupported element type '%s' for field '%s'", gfi.goValue.Type().Elem(), gfi.path())
+		}
 		if err != nil {
 			return err
 		}
+	}
 
-		prevVal = &val
+	if !gfi.set && gfi.goValue.Len() == 0 {
+		if def, ok := gfi.keys["default"]; ok {
+			gfi.printf("Using default value '%s' for field '%s'", def, gfi.field)
+			gfi.set = true
+			gfi.defaulted = true
+			return appendSliceType(gfi.goValue, splitDefault(def), gfi.keys, gfi.printf)
+		}
+	}
 
-		printf("Got value '%s' from source '%s' for key '%s'", val, source, gfi.field)
+	return nil
+}
 
-		switch gfi.goField.Type.Kind() {
-		case reflect.Bool:
-			if len(val) == 0 {
-				printf("Setting bool value to false")
-				val = "false"
-			}
-			b, err := strconv.ParseBool(val)
+// populateArrayType sets the array from the last source with
+// a value, which must have the same number of values as the array
+func (gfi *gofiguritem) populateArrayType(order []string) error {
+	var val []string
+
+	for _, source := range order {
+		kn := gfi.key(source)
+
+		gfi.printf("Looking for field '%s' with key '%s' in source '%s'", gfi.field, kn, source)
+		v, err := gfi.source(source).GetArray(kn, &[]string{})
+		if err != nil {
+			return err
+		}
+
+		gfi.printf("Got value '%+v' from array source '%s' for key '%s'", v, source, gfi.field)
+
+		if len(v) > 0 {
+			gfi.set = true
+			val = v
+		}
+	}
+
+	if !gfi.set {
+		def, ok := gfi.keys["default"]
+		if !ok || !gfi.isZero() {
+			return nil
+		}
+		gfi.printf("Using default value '%s' for field '%s'", def, gfi.field)
+		val = splitDefault(def)
+		gfi.set = true
+		gfi.defaulted = true
+	}
+
+	err := setArrayType(gfi.goValue, val, gfi.keys, gfi.printf)
+	if err == ErrUnsupportedFieldType {
+		return fmt.Errorf("Unsupported element type '%s' for field '%s'", gfi.goValue.Type().Elem(), gfi.path())
+	}
+	if err != nil {
+		return fmt.Errorf("Invalid value for field '%s': %s", gfi.path(), err)
+	}
+	return nil
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+var stringType = reflect.TypeOf("")
+
+// isTextUnmarshaler returns true if t or *t implements encoding.TextUnmarshaler
+func isTextUnmarshaler(t reflect.Type) bool {
+	return t.Implements(textUnmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+func textUnmarshaler(v reflect.Value) (encoding.TextUnmarshaler, bool) {
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u, true
+		}
+	}
+	return nil, false
+}
+
+// setDefaultType converts val to the type of v and sets it
+func setDefaultType(v reflect.Value, val string, keys map[string]string, printf func(message string, args ...interface{})) error {
+	if isTimeType(v.Type()) {
+		return setTimeType(v, val, keys)
+	}
+	if u, ok := textUnmarshaler(v); ok {
+		return u.UnmarshalText([]byte(val))
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if len(val) == 0 {
+			printf("Setting bool value to false")
+			val = "false"
+		}
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int:
+		i, err := strconv.ParseInt(numVal(val), 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Int8:
+		i, err := strconv.ParseInt(numVal(val), 10, 8)
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Int16:
+		i, err := strconv.ParseInt(numVal(val), 10, 16)
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Int32:
+		i, err := strconv.ParseInt(numVal(val), 10, 32)
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Int64:
+		i, err := strconv.ParseInt(numVal(val), 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint:
+		i, err := strconv.ParseUint(numVal(val), 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetUint(i)
+	case reflect.Uint8:
+		i, err := strconv.ParseUint(numVal(val), 10, 8)
+		if err != nil {
+			return err
+		}
+		v.SetUint(i)
+	case reflect.Uint16:
+		i, err := strconv.ParseUint(numVal(val), 10, 16)
+		if err != nil {
+			return err
+		}
+		v.SetUint(i)
+	case reflect.Uint32:
+		i, err := strconv.ParseUint(numVal(val), 10, 32)
+		if err != nil {
+			return err
+		}
+		v.SetUint(i)
+	case reflect.Uint64:
+		i, err := strconv.ParseUint(numVal(val), 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetUint(i)
+	case reflect.Float32:
+		f, err := strconv.ParseFloat(numVal(val), 32)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(numVal(val), 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.String:
+		v.SetString(val)
+	default:
+		return ErrUnsupportedFieldType
+	}
+
+	return nil
+}
+
+// appendSliceType converts each of val to the element type
+// of the slice v and appends it
+func appendSliceType(v reflect.Value, val []string, keys map[string]string, printf func(message string, args ...interface{})) error {
+	if isTimeType(v.Type().Elem()) || isTextUnmarshaler(v.Type().Elem()) {
+		for _, s := range val {
+			printf("Appending %s value '%s' to slice", v.Type().Elem(), s)
+			e := reflect.New(v.Type().Elem()).Elem()
+			err := setDefaultType(e, s, keys, printf)
 			if err != nil {
 				return err
 			}
-			gfi.goValue.SetBool(b)
+			v.Set(reflect.Append(v, e))
+		}
+		return nil
+	}
+
+	switch v.Type().Kind() {
+	case reflect.Slice:
+		switch v.Type().Elem().Kind() {
+		case reflect.String:
+			for _, s := range val {
+				printf("Appending string value '%s' to slice", s)
+				v.Set(reflect.Append(v, reflect.ValueOf(s)))
+			}
 		case reflect.Int:
-			i, err := strconv.ParseInt(numVal(val), 10, 64)
-			if err != nil {
-				return err
+			for _, s := range val {
+				printf("Appending int value '%s' to slice", s)
+				i, err := strconv.ParseInt(numVal(s), 10, 64)
+				if err != nil {
+					return err
+				}
+				v.Set(reflect.Append(v, reflect.ValueOf(int(i))))
 			}
-			gfi.goValue.SetInt(i)
 		case reflect.Int8:
-			i, err := strconv.ParseInt(numVal(val), 10, 8)
-			if err != nil {
-				return err
+			for _, s := range val {
+				printf("Appending int8 value '%s' to slice", s)
+				i, err := strconv.ParseInt(numVal(s), 10, 8)
+				if err != nil {
+					return err
+				}
+				v.Set(reflect.Append(v, reflect.ValueOf(int8(i))))
 			}
-			gfi.goValue.SetInt(i)
 		case reflect.Int16:
-			i, err := strconv.ParseInt(numVal(val), 10, 16)
-			if err != nil {
-				return err
+			for _, s := range val {
+				printf("Appending int16 value '%s' to slice", s)
+				i, err := strconv.ParseInt(numVal(s), 10, 16)
+				if err != nil {
+					return err
+				}
+				v.Set(reflect.Append(v, reflect.ValueOf(int16(i))))
 			}
-			gfi.goValue.SetInt(i)
 		case reflect.Int32:
-			i, err := strconv.ParseInt(numVal(val), 10, 32)
-			if err != nil {
-				return err
+			for _, s := range val {
+				printf("Appending int32 value '%s' to slice", s)
+				i, err := strconv.ParseInt(numVal(s), 10, 32)
+				if err != nil {
+					return err
+				}
+				v.Set(reflect.Append(v, reflect.ValueOf(int32(i))))
 			}
-			gfi.goValue.SetInt(i)
 		case reflect.Int64:
-			i, err := strconv.ParseInt(numVal(val), 10, 64)
-			if err != nil {
-				return err
+			for _, s := range val {
+				printf("Appending int64 value '%s' to slice", s)
+				i, err := strconv.ParseInt(numVal(s), 10, 64)
+				if err != nil {
+					return err
+				}
+				v.Set(reflect.Append(v, reflect.ValueOf(int64(i))))
 			}
-			gfi.goValue.SetInt(i)
 		case reflect.Uint:
-			i, err := strconv.ParseUint(numVal(val), 10, 64)
-			if err != nil {
-				return err
+			for _, s := range val {
+				printf("Appending uint value '%s' to slice", s)
+				i, err := strconv.ParseUint(numVal(s), 10, 64)
+				if err != nil {
+					return err
+				}
+				v.Set(reflect.Append(v, reflect.ValueOf(uint(i))))
 			}
-			gfi.goValue.SetUint(i)
 		case reflect.Uint8:
-			i, err := strconv.ParseUint(numVal(val), 10, 8)
-			if err != nil {
-				return err
+			for _, s := range val {
+				printf("Appending uint8 value '%s' to slice", s)
+				i, err := strconv.ParseUint(numVal(s), 10, 8)
+				if err != nil {
+					return err
+				}
+				v.Set(reflect.Append(v, reflect.ValueOf(uint8(i))))
 			}
-			gfi.goValue.SetUint(i)
 		case reflect.Uint16:
-			i, err := strconv.ParseUint(numVal(val), 10, 16)
-			if err != nil {
-				return err
+			for _, s := range val {
+				printf("Appending uint16 value '%s' to slice", s)
+				i, err := strconv.ParseUint(numVal(s), 10, 16)
+				if err != nil {
+					return err
+				}
+				v.Set(reflect.Append(v, reflect.ValueOf(uint16(i))))
 			}
-			gfi.goValue.SetUint(i)
 		case reflect.Uint32:
-			i, err := strconv.ParseUint(numVal(val), 10, 32)
-			if err != nil {
-				return err
+			for _, s := range val {
+				printf("Appending uint32 value '%s' to slice", s)
+				i, err := strconv.ParseUint(numVal(s), 10, 32)
+				if err != nil {
+					return err
+				}
+				v.Set(reflect.Append(v, reflect.ValueOf(uint32(i))))
 			}
-			gfi.goValue.SetUint(i)
 		case reflect.Uint64:
-			i, err := strconv.ParseUint(numVal(val), 10, 64)
-			if err != nil {
-				return err
+			for _, s := range val {
+				printf("Appending uint64 value '%s' to slice", s)
+				i, err := strconv.ParseUint(numVal(s), 10, 64)
+				if err != nil {
+					return err
+				}
+				v.Set(reflect.Append(v, reflect.ValueOf(uint64(i))))
 			}
-			gfi.goValue.SetUint(i)
 		case reflect.Float32:
-			f, err := strconv.ParseFloat(numVal(val), 32)
-			if err != nil {
-				return err
+			for _, s := range val {
+				printf("Appending float32 value '%s' to slice", s)
+				f, err := strconv.ParseFloat(numVal(s), 32)
+				if err != nil {
+					return err
+				}
+				v.Set(reflect.Append(v, reflect.ValueOf(float32(f))))
 			}
-			gfi.goValue.SetFloat(f)
 		case reflect.Float64:
-			f, err := strconv.ParseFloat(numVal(val), 64)
-			if err != nil {
-				return err
+			for _, s := range val {
+				printf("Appending float64 value '%s' to slice", s)
+				f, err := strconv.ParseFloat(numVal(s), 64)
+				if err != nil {
+					return err
+				}
+				v.Set(reflect.Append(v, reflect.ValueOf(f)))
+			}
+		case reflect.Bool:
+			for _, s := range val {
+				printf("Appending bool value '%s' to slice", s)
+				if len(s) == 0 {
+					s = "false"
+				}
+				b, err := strconv.ParseBool(s)
+				if err != nil {
+					return err
+				}
+				v.Set(reflect.Append(v, reflect.ValueOf(b)))
 			}
-			gfi.goValue.SetFloat(f)
-		case reflect.String:
-			gfi.goValue.SetString(val)
 		default:
 			return ErrUnsupportedFieldType
 		}
@@ -414,136 +964,40 @@ func (gfi *gofiguritem) populateDefaultType(order []string) error {
 	return nil
 }
 
-func (gfi *gofiguritem) populateSliceType(order []string) error {
-	var prevVal *[]string
-
-	for _, source := range order {
-		kn := gfi.field
-		if k, ok := gfi.keys[source]; ok {
-			kn = k
-		}
-
-		printf("Looking for field '%s' with key '%s' in source '%s'", gfi.field, kn, source)
-		val, err := Sources[source].GetArray(kn, prevVal)
-		if err != nil {
-			return err
-		}
-
-		// This causes duplication between array sources depending on order
-		//prevVal = &val
-
-		printf("Got value '%+v' from array source '%s' for key '%s'", val, source, gfi.field)
-
-		switch gfi.goField.Type.Kind() {
-		case reflect.Slice:
-			switch gfi.goField.Type.Elem().Kind() {
-			case reflect.String:
-				for _, s := range val {
-					printf("Appending string value '%s' to slice", s)
-					gfi.goValue.Set(reflect.Append(gfi.goValue, reflect.ValueOf(s)))
-				}
-			case reflect.Int:
-				for _, s := range val {
-					printf("Appending int value '%s' to slice", s)
-					i, err := strconv.ParseInt(numVal(s), 10, 64)
-					if err != nil {
-						return err
-					}
-					gfi.goValue.Set(reflect.Append(gfi.goValue, reflect.ValueOf(int(i))))
-				}
-			case reflect.Int8:
-				for _, s := range val {
-					printf("Appending int8 value '%s' to slice", s)
-					i, err := strconv.ParseInt(numVal(s), 10, 8)
-					if err != nil {
-						return err
-					}
-					gfi.goValue.Set(reflect.Append(gfi.goValue, reflect.ValueOf(int8(i))))
-				}
-			case reflect.Int16:
-				for _, s := range val {
-					printf("Appending int16 value '%s' to slice", s)
-					i, err := strconv.ParseInt(numVal(s), 10, 16)
-					if err != nil {
-						return err
-					}
-					gfi.goValue.Set(reflect.Append(gfi.goValue, reflect.ValueOf(int16(i))))
-				}
-			case reflect.Int32:
-				for _, s := range val {
-					printf("Appending int32 value '%s' to slice", s)
-					i, err := strconv.ParseInt(numVal(s), 10, 32)
-					if err != nil {
-						return err
-					}
-					gfi.goValue.Set(reflect.Append(gfi.goValue, reflect.ValueOf(int32(i))))
-				}
-			case reflect.Int64:
-				for _, s := range val {
-					printf("Appending int64 value '%s' to slice", s)
-					i, err := strconv.ParseInt(numVal(s), 10, 64)
-					if err != nil {
-						return err
-					}
-					gfi.goValue.Set(reflect.Append(gfi.goValue, reflect.ValueOf(int64(i))))
-				}
-			case reflect.Uint:
-				for _, s := range val {
-					printf("Appending uint value '%s' to slice", s)
-					i, err := strconv.ParseUint(numVal(s), 10, 64)
-					if err != nil {
-						return err
-					}
-					gfi.goValue.Set(reflect.Append(gfi.goValue, reflect.ValueOf(uint(i))))
-				}
-			case reflect.Uint8:
-				for _, s := range val {
-					printf("Appending uint8 value '%s' to slice", s)
-					i, err := strconv.ParseUint(numVal(s), 10, 8)
-					if err != nil {
-						return err
-					}
-					gfi.goValue.Set(reflect.Append(gfi.goValue, reflect.ValueOf(uint8(i))))
-				}
-			case reflect.Uint16:
-				for _, s := range val {
-					printf("Appending uint16 value '%s' to slice", s)
-					i, err := strconv.ParseUint(numVal(s), 10, 16)
-					if err != nil {
-						return err
-					}
-					gfi.goValue.Set(reflect.Append(gfi.goValue, reflect.ValueOf(uint16(i))))
-				}
-			case reflect.Uint32:
-				for _, s := range val {
-					printf("Appending uint32 value '%s' to slice", s)
-					i, err := strconv.ParseUint(numVal(s), 10, 32)
-					if err != nil {
-						return err
-					}
-					gfi.goValue.Set(reflect.Append(gfi.goValue, reflect.ValueOf(uint32(i))))
-				}
-			case reflect.Uint64:
-				for _, s := range val {
-					printf("Appending uint64 value '%s' to slice", s)
-					i, err := strconv.ParseUint(numVal(s), 10, 64)
-					if err != nil {
-						return err
-					}
-					gfi.goValue.Set(reflect.Append(gfi.goValue, reflect.ValueOf(uint64(i))))
-				}
-			// TODO floats
-			default:
-				//return ErrUnsupportedFieldType
-			}
-		}
+// setArrayType converts each of val to the element type of the
+// array v and sets it, returning an error if the lengths differ
+func setArrayType(v reflect.Value, val []string, keys map[string]string, printf func(message string, args ...interface{})) error {
+	s := reflect.New(reflect.SliceOf(v.Type().Elem())).Elem()
+	err := appendSliceType(s, val, keys, printf)
+	if err != nil {
+		return err
 	}
-
+	if s.Len() != v.Len() {
+		return fmt.Errorf("expected %d values, got %d", v.Len(), s.Len())
+	}
+	reflect.Copy(v, s)
 	return nil
 }
 
 func (gfi *gofiguritem) populateStructType(order []string) error {
-	return gfi.inner.populateStruct()
+	err := gfi.inner.populateStruct()
+	if err != nil {
+		return err
+	}
+	gfi.set = gfi.inner.isSet()
+	return nil
+}
+
+// isSet returns true if any field has been set by a source. Values
+// from default tags are ignored, so pointers to structs are only
+// allocated if a source provides a value.
+func (gfg *gofiguration) isSet() bool {
+	for _, gfi := range gfg.fields {
+		if gfi.set && !gfi.defaulted {
+			return true
+		}
+	}
+	return false
 }
 
 func (gfg *gofiguration) populateStruct() error {
@@ -552,8 +1006,18 @@ func (gfg *gofiguration) populateStruct() error {
 	}
 
 	for _, gfi := range gfg.fields {
-		printf("Populating field %s", gfi.field)
-		switch gfi.goField.Type.Kind() {
+		gfg.printf("Populating field %s", gfi.field)
+		if isTextUnmarshaler(gfi.goValue.Type()) {
+			gfg.printf("Calling populateDefaultType")
+			err := gfi.populateDefaultType(gfi.sourceOrder(gfg.order))
+			if err != nil {
+				return err
+			}
+			gfi.assign()
+			continue
+		}
+
+		switch gfi.goValue.Kind() {
 		case reflect.Invalid, reflect.Uintptr, reflect.Complex64,
 			reflect.Complex128, reflect.Chan, reflect.Func,
 			reflect.Ptr, reflect.UnsafePointer:
@@ -562,39 +1026,43 @@ func (gfg *gofiguration) populateStruct() error {
 			// TODO
 			return ErrUnsupportedFieldType
 		case reflect.Map:
-			// TODO
-			return ErrUnsupportedFieldType
+			gfg.printf("Calling populateMapType")
+			err := gfi.populateMapType(gfi.sourceOrder(gfg.order))
+			if err != nil {
+				return err
+			}
 		case reflect.Slice:
-			printf("Calling populateSliceType")
-			err := gfi.populateSliceType(gfg.order)
+			gfg.printf("Calling populateSliceType")
+			err := gfi.populateSliceType(gfi.sourceOrder(gfg.order))
 			if err != nil {
 				return err
 			}
 		case reflect.Struct:
-			printf("Calling populateStructType")
-			err := gfi.populateStructType(gfg.order)
+			gfg.printf("Calling populateStructType")
+			err := gfi.populateStructType(gfi.sourceOrder(gfg.order))
 			if err != nil {
 				return err
 			}
 		case reflect.Array:
-			// TODO
-			return ErrUnsupportedFieldType
+			gfg.printf("Calling populateArrayType")
+			err := gfi.populateArrayType(gfi.sourceOrder(gfg.order))
+			if err != nil {
+				return err
+			}
 		default:
-			printf("Calling populateDefaultType")
-			err := gfi.populateDefaultType(gfg.order)
+			gfg.printf("Calling populateDefaultType")
+			err := gfi.populateDefaultType(gfi.sourceOrder(gfg.order))
 			if err != nil {
 				return err
 			}
 		}
-	}
 
-	for _, c := range gfg.children {
-		err := c.populateStruct()
-		if err != nil {
-			return err
-		}
+		gfi.assign()
 	}
 
+	// Nested structs in gfg.children have already been
+	// populated by populateStructType
+
 	return nil
 }
 
@@ -619,7 +1087,19 @@ func (gfg *gofiguration) apply(parent *gofiguration) error {
 	}
 
 	if parent == nil {
-		return gfg.populateStruct()
+		err = gfg.populateStruct()
+		if err != nil {
+			return err
+		}
+		err = gfg.checkRequired()
+		if err != nil {
+			return err
+		}
+		err = gfg.validate()
+		if err != nil {
+			return err
+		}
+		return gfg.callValidate()
 	}
 
 	return nil
@@ -627,11 +1107,14 @@ func (gfg *gofiguration) apply(parent *gofiguration) error {
 
 // Gofigure parses and applies the configuration defined by the struct.
 //
-// It returns ErrUnsupportedType if s is not a pointer to a struct.
+// It returns ErrUnsupportedType if s is not a pointer to a struct,
+// a *MissingFieldsError if any required fields have no value, or
+// ValidationErrors if any fields fail validation. If the struct or
+// any nested struct has a Validate() error method, it is called after
+// all fields are populated and any error is returned as a *StructError.
+//
+// It uses the sources in Sources, the order in DefaultOrder and the
+// global flag.CommandLine. Use New to create an isolated Loader.
 func Gofigure(s interface{}) error {
-	gfg, err := parseStruct(s)
-	if err != nil {
-		return err
-	}
-	return gfg.apply(nil)
+	return defaultLoader().Gofigure(s)
 }
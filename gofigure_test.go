@@ -4,10 +4,13 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/ian-kent/gofigure/sources"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -145,7 +148,7 @@ type MyConfigFull struct {
 
 func TestParseStruct(t *testing.T) {
 	Convey("parseStruct should return an error unless given a pointer to a struct", t, func() {
-		info, e := parseStruct(1)
+		info, e := defaultLoader().parseStruct(1)
 		So(e, ShouldNotBeNil)
 		So(e, ShouldEqual, ErrUnsupportedType)
 		So(info, ShouldBeNil)
@@ -153,77 +156,71 @@ func TestParseStruct(t *testing.T) {
 
 	Convey("parseStruct should keep a reference to the struct", t, func() {
 		ref := &MyConfigFoo{}
-		info, e := parseStruct(ref)
+		info, e := defaultLoader().parseStruct(ref)
 		So(e, ShouldBeNil)
 		So(info, ShouldNotBeNil)
 		So(info.s, ShouldEqual, ref)
 	})
 
 	Convey("parseStruct should read gofigure envPrefix tag correctly", t, func() {
-		info, e := parseStruct(&MyConfigFoo{})
+		info, e := defaultLoader().parseStruct(&MyConfigFoo{})
 		So(e, ShouldBeNil)
 		So(info, ShouldNotBeNil)
 		So(info.params["env"]["prefix"], ShouldEqual, "FOO")
 
-		info, e = parseStruct(&MyConfigBar{})
+		info, e = defaultLoader().parseStruct(&MyConfigBar{})
 		So(e, ShouldBeNil)
 		So(info, ShouldNotBeNil)
 		So(info.params["env"]["prefix"], ShouldEqual, "BAR")
 	})
 
 	Convey("parseStruct should read gofigure order tag correctly", t, func() {
-		info, e := parseStruct(&MyConfigFoo{})
+		info, e := defaultLoader().parseStruct(&MyConfigFoo{})
 		So(e, ShouldBeNil)
 		So(info, ShouldNotBeNil)
 		So(info.order, ShouldResemble, []string{"env", "flag"})
 
-		info, e = parseStruct(&MyConfigBar{})
+		info, e = defaultLoader().parseStruct(&MyConfigBar{})
 		So(e, ShouldBeNil)
 		So(info, ShouldNotBeNil)
 		So(info.order, ShouldResemble, []string{"flag", "env"})
 	})
 
 	Convey("Invalid order should return error", t, func() {
-		info, e := parseStruct(&MyConfigBaz{})
+		info, e := defaultLoader().parseStruct(&MyConfigBaz{})
 		So(e, ShouldNotBeNil)
 		So(e, ShouldEqual, ErrInvalidOrder)
 		So(info, ShouldBeNil)
 	})
 
 	Convey("parseStruct should read fields correctly", t, func() {
-		info, e := parseStruct(&MyConfigFoo{})
+		info, e := defaultLoader().parseStruct(&MyConfigFoo{})
 		So(e, ShouldBeNil)
 		So(info, ShouldNotBeNil)
 		So(len(info.fields), ShouldEqual, 1)
-		_, ok := info.fields["BindAddr"]
-		So(ok, ShouldEqual, true)
-		So(info.fields["BindAddr"].field, ShouldEqual, "BindAddr")
-		So(info.fields["BindAddr"].keys["env"], ShouldEqual, "BIND_ADDR")
-		So(info.fields["BindAddr"].keys["flag"], ShouldEqual, "bind-addr")
-		So(info.fields["BindAddr"].goField, ShouldNotBeNil)
-		So(info.fields["BindAddr"].goField.Type.Kind(), ShouldEqual, reflect.String)
-		So(info.fields["BindAddr"].goValue, ShouldNotBeNil)
-
-		info, e = parseStruct(&MyConfigBar{})
+		So(info.fields[0].field, ShouldEqual, "BindAddr")
+		So(info.fields[0].keys["env"], ShouldEqual, "BIND_ADDR")
+		So(info.fields[0].keys["flag"], ShouldEqual, "bind-addr")
+		So(info.fields[0].goField, ShouldNotBeNil)
+		So(info.fields[0].goField.Type.Kind(), ShouldEqual, reflect.String)
+		So(info.fields[0].goValue, ShouldNotBeNil)
+
+		info, e = defaultLoader().parseStruct(&MyConfigBar{})
 		So(e, ShouldBeNil)
 		So(info, ShouldNotBeNil)
 		So(len(info.fields), ShouldEqual, 2)
-		_, ok = info.fields["RemoteAddr"]
-		So(ok, ShouldEqual, true)
-		So(info.fields["RemoteAddr"].field, ShouldEqual, "RemoteAddr")
-		So(info.fields["RemoteAddr"].keys["env"], ShouldEqual, "REMOTE_ADDR")
-		So(info.fields["RemoteAddr"].keys["flag"], ShouldEqual, "remote-addr")
-		So(info.fields["RemoteAddr"].goField, ShouldNotBeNil)
-		So(info.fields["RemoteAddr"].goField.Type.Kind(), ShouldEqual, reflect.String)
-		So(info.fields["RemoteAddr"].goValue, ShouldNotBeNil)
-		_, ok = info.fields["LocalAddr"]
-		So(ok, ShouldEqual, true)
-		So(info.fields["LocalAddr"].field, ShouldEqual, "LocalAddr")
-		So(info.fields["LocalAddr"].keys["env"], ShouldEqual, "LOCAL_ADDR")
-		So(info.fields["LocalAddr"].keys["flag"], ShouldEqual, "local-addr")
-		So(info.fields["LocalAddr"].goField, ShouldNotBeNil)
-		So(info.fields["LocalAddr"].goField.Type.Kind(), ShouldEqual, reflect.String)
-		So(info.fields["LocalAddr"].goValue, ShouldNotBeNil)
+		So(info.fields[0].field, ShouldEqual, "RemoteAddr")
+		So(info.fields[0].keys["env"], ShouldEqual, "REMOTE_ADDR")
+		So(info.fields[0].keys["flag"], ShouldEqual, "remote-addr")
+		So(info.fields[0].goField, ShouldNotBeNil)
+		So(info.fields[0].goField.Type.Kind(), ShouldEqual, reflect.String)
+		So(info.fields[0].goValue, ShouldNotBeNil)
+		So(info.fields[1].field, ShouldEqual, "LocalAddr")
+		So(info.fields[1].keys["env"], ShouldEqual, "LOCAL_ADDR")
+		So(info.fields[1].keys["flag"], ShouldEqual, "local-addr")
+		So(info.fields[1].goField, ShouldNotBeNil)
+		So(info.fields[1].goField.Type.Kind(), ShouldEqual, reflect.String)
+		So(info.fields[1].goValue, ShouldNotBeNil)
 	})
 
 	clear()
@@ -585,5 +582,899 @@ func TestArrayField(t *testing.T) {
 		So(cfg.ArrayIntField, ShouldResemble, []int{1, 2})
 	})
 
+	Convey("Float and bool arrays should work", t, func() {
+		os.Clearenv()
+		os.Args = []string{
+			"gofigure",
+			"-ratio", "0.5",
+			"-ratio", "1.25",
+			"-weight", "2",
+			"-flag", "true",
+			"-flag", "false",
+		}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg struct {
+			gofigure interface{}
+			Ratios   []float64 `flag:"ratio"`
+			Weights  []float32 `flag:"weight"`
+			Flags    []bool    `flag:"flag"`
+		}
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.Ratios, ShouldResemble, []float64{0.5, 1.25})
+		So(cfg.Weights, ShouldResemble, []float32{2})
+		So(cfg.Flags, ShouldResemble, []bool{true, false})
+	})
+
+	Convey("Invalid float and bool array values should return an error", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-flag", "maybe"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg struct {
+			gofigure interface{}
+			Flags    []bool `flag:"flag"`
+		}
+		err := Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Unsupported array element types should return an error", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg struct {
+			gofigure interface{}
+			Channels []chan int
+		}
+		err := Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "Unsupported element type 'chan int' for field 'Channels'")
+	})
+
 	clear()
 }
+
+// MyConfigFixedArray is used to test fixed size array fields
+type MyConfigFixedArray struct {
+	gofigure interface{} `order:"env,flag"`
+	Replicas [3]string   `env:"REPLICAS" flag:"replica"`
+	IP       [4]byte     `env:"IP" flag:"ip" default:"127,0,0,1"`
+	Weights  [2]float64  `env:"WEIGHTS" flag:"weight" min:"0"`
+}
+
+func TestFixedArrayField(t *testing.T) {
+	Convey("Fixed size arrays should be populated", t, func() {
+		os.Clearenv()
+		os.Setenv("GOFIGURE_ENV_ARRAY", "1")
+		os.Setenv("IP", "10,0,0,1")
+		os.Setenv("WEIGHTS", "0.5,1")
+		os.Args = []string{"gofigure", "-replica", "a", "-replica", "b", "-replica", "c", "-weight", "2", "-weight", "3"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigFixedArray
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.Replicas, ShouldResemble, [3]string{"a", "b", "c"})
+		So(cfg.IP, ShouldResemble, [4]byte{10, 0, 0, 1})
+		So(cfg.Weights, ShouldResemble, [2]float64{2, 3})
+	})
+
+	Convey("Fixed size arrays should support defaults and keep existing values", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		cfg := MyConfigFixedArray{Replicas: [3]string{"x", "y", "z"}}
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.Replicas, ShouldResemble, [3]string{"x", "y", "z"})
+		So(cfg.IP, ShouldResemble, [4]byte{127, 0, 0, 1})
+		So(cfg.Weights, ShouldResemble, [2]float64{0, 0})
+	})
+
+	Convey("Fixed size arrays should return an error for the wrong number of values", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-replica", "a", "-replica", "b"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigFixedArray
+		err := Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "Invalid value for field 'Replicas': expected 3 values, got 2")
+	})
+
+	Convey("Fixed size arrays should validate elements", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-weight", "1", "-weight", "-1"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigFixedArray
+		err := Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "Invalid field values: Weights: value -1 violates min=0")
+	})
+
+	clear()
+}
+
+// MyConfigJSON is used to test the JSON file source
+type MyConfigJSON struct {
+	gofigure   interface{} `order:"json,env,flag" jsonPath:"testdata/config.json"`
+	RemoteAddr string      `json:"remote_addr" env:"REMOTE_ADDR" flag:"remote-addr"`
+	NumCPU     int         `json:"num_cpu" env:"NUM_CPU" flag:"num-cpu"`
+	Sources    []string    `json:"sources" env:"SOURCES" flag:"source"`
+	Advanced   struct {
+		MaxBytes int64 `json:"max_bytes" env:"MAX_BYTES" flag:"max-bytes"`
+	} `json:"advanced"`
+}
+
+func TestJSONFile(t *testing.T) {
+	Convey("Gofigure should read values from a JSON file", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigJSON
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.RemoteAddr, ShouldEqual, "localhost:8080")
+		So(cfg.NumCPU, ShouldEqual, 4)
+		So(cfg.Sources, ShouldResemble, []string{"test1.local", "test2.local"})
+		So(cfg.Advanced.MaxBytes, ShouldEqual, 1024)
+	})
+
+	Convey("Later sources should override the JSON file", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-num-cpu", "8", "-max-bytes", "2048"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Setenv("REMOTE_ADDR", "localhost:9090")
+		var cfg MyConfigJSON
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.RemoteAddr, ShouldEqual, "localhost:9090")
+		So(cfg.NumCPU, ShouldEqual, 8)
+		So(cfg.Advanced.MaxBytes, ShouldEqual, 2048)
+	})
+
+	clear()
+}
+
+// MyConfigYAML is used to test the YAML file source
+type MyConfigYAML struct {
+	gofigure   interface{} `order:"yaml,env,flag" yamlPath:"testdata/config.yaml"`
+	RemoteAddr string      `yaml:"remote_addr" env:"REMOTE_ADDR" flag:"remote-addr"`
+	NumCPU     int         `yaml:"num_cpu" env:"NUM_CPU" flag:"num-cpu"`
+	Sources    []string    `yaml:"sources" env:"SOURCES" flag:"source"`
+	Numbers    []int       `yaml:"numbers" env:"NUMBERS" flag:"number"`
+	Advanced   struct {
+		MaxBytes int64 `yaml:"max_bytes" env:"MAX_BYTES" flag:"max-bytes"`
+	}
+}
+
+func TestYAMLFile(t *testing.T) {
+	Convey("Gofigure should read values from a YAML file", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigYAML
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.RemoteAddr, ShouldEqual, "localhost:8080")
+		So(cfg.NumCPU, ShouldEqual, 4)
+		So(cfg.Sources, ShouldResemble, []string{"test1.local", "test2.local"})
+		So(cfg.Numbers, ShouldResemble, []int{1, 2, 3})
+		So(cfg.Advanced.MaxBytes, ShouldEqual, 1024)
+	})
+
+	clear()
+}
+
+// MyConfigTOML is used to test the TOML file source
+type MyConfigTOML struct {
+	gofigure   interface{} `order:"toml,env,flag" tomlPath:"testdata/config.toml"`
+	RemoteAddr string      `toml:"remote_addr" env:"REMOTE_ADDR" flag:"remote-addr"`
+	NumCPU     int         `toml:"num_cpu" env:"NUM_CPU" flag:"num-cpu"`
+	Sources    []string    `toml:"sources" env:"SOURCES" flag:"source"`
+	Numbers    []int       `toml:"numbers" env:"NUMBERS" flag:"number"`
+	Advanced   struct {
+		MaxBytes int64 `toml:"max_bytes" env:"MAX_BYTES" flag:"max-bytes"`
+	}
+}
+
+func TestTOMLFile(t *testing.T) {
+	Convey("parseStruct should read the tomlPath param", t, func() {
+		info, e := defaultLoader().parseStruct(&MyConfigTOML{})
+		So(e, ShouldBeNil)
+		So(info.params["toml"]["path"], ShouldEqual, "testdata/config.toml")
+	})
+
+	Convey("Gofigure should read values from a TOML file", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigTOML
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.RemoteAddr, ShouldEqual, "localhost:8080")
+		So(cfg.NumCPU, ShouldEqual, 4)
+		So(cfg.Sources, ShouldResemble, []string{"test1.local", "test2.local"})
+		So(cfg.Numbers, ShouldResemble, []int{1, 2, 3})
+		So(cfg.Advanced.MaxBytes, ShouldEqual, 1024)
+	})
+
+	clear()
+}
+
+// MyConfigDotEnv is used to test the dotenv file source
+type MyConfigDotEnv struct {
+	gofigure   interface{} `order:"dotenv,env,flag" dotenvPath:"testdata/config.env"`
+	RemoteAddr string      `dotenv:"REMOTE_ADDR" env:"REMOTE_ADDR" flag:"remote-addr"`
+	NumCPU     int         `dotenv:"NUM_CPU" env:"NUM_CPU" flag:"num-cpu"`
+	Advanced   struct {
+		MaxBytes int64 `env:"MAX_BYTES" flag:"max-bytes"`
+	}
+}
+
+func TestDotEnv(t *testing.T) {
+	Convey("Gofigure should read values from a .env file", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Setenv("NUM_CPU", "8")
+		var cfg MyConfigDotEnv
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.RemoteAddr, ShouldEqual, "localhost:8080")
+		So(cfg.NumCPU, ShouldEqual, 8)
+		So(cfg.Advanced.MaxBytes, ShouldEqual, 1024)
+	})
+
+	clear()
+}
+
+// MyConfigINI is used to test the INI and properties file sources
+type MyConfigINI struct {
+	gofigure   interface{} `order:"ini,properties,env,flag" iniPath:"testdata/config.ini" propertiesPath:"testdata/config.properties"`
+	RemoteAddr string      `ini:"remote_addr" properties:"remote_addr" env:"REMOTE_ADDR" flag:"remote-addr"`
+	NumCPU     int         `ini:"num_cpu" properties:"num_cpu" env:"NUM_CPU" flag:"num-cpu"`
+	Advanced   struct {
+		MaxBytes int64 `ini:"max_bytes" properties:"max_bytes" env:"MAX_BYTES" flag:"max-bytes"`
+	}
+}
+
+func TestINIAndProperties(t *testing.T) {
+	Convey("Gofigure should read values from INI and properties files", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigINI
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.RemoteAddr, ShouldEqual, "localhost:8080")
+		So(cfg.NumCPU, ShouldEqual, 4)
+		So(cfg.Advanced.MaxBytes, ShouldEqual, 1024)
+	})
+
+	clear()
+}
+
+// MyConfigFile is used to test the file source
+type MyConfigFile struct {
+	gofigure   interface{} `order:"file,env,flag" filePath:"testdata/config.yaml"`
+	RemoteAddr string      `file:"remote_addr" env:"REMOTE_ADDR" flag:"remote-addr"`
+	Advanced   struct {
+		MaxBytes int64 `file:"max_bytes" env:"MAX_BYTES" flag:"max-bytes"`
+	}
+}
+
+func TestFile(t *testing.T) {
+	Convey("Gofigure should read values from a file using its extension", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigFile
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.RemoteAddr, ShouldEqual, "localhost:8080")
+		So(cfg.Advanced.MaxBytes, ShouldEqual, 1024)
+	})
+
+	clear()
+}
+
+// MyConfigDir is used to test the directory source
+type MyConfigDir struct {
+	gofigure   interface{} `order:"dir,env,flag" dirPath:"testdata/secrets"`
+	DbPassword string      `env:"DB_PASSWORD" flag:"db-password"`
+	DbUser     string      `env:"DB_USER" flag:"db-user"`
+}
+
+func TestDir(t *testing.T) {
+	Convey("Gofigure should read values from a directory", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Setenv("DB_USER", "admin")
+		var cfg MyConfigDir
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.DbPassword, ShouldEqual, "hunter2")
+		So(cfg.DbUser, ShouldEqual, "admin")
+	})
+
+	clear()
+}
+
+// MyConfigEnvFile is used to test the envFileSuffix param
+type MyConfigEnvFile struct {
+	gofigure   interface{} `envPrefix:"FOO" envFileSuffix:"_FILE"`
+	DbPassword string      `env:"DB_PASSWORD" flag:"db-password"`
+}
+
+func TestEnvFileSuffix(t *testing.T) {
+	Convey("parseStruct should read multi-word params", t, func() {
+		info, e := defaultLoader().parseStruct(&MyConfigEnvFile{})
+		So(e, ShouldBeNil)
+		So(info.params["env"]["prefix"], ShouldEqual, "FOO")
+		So(info.params["env"]["fileSuffix"], ShouldEqual, "_FILE")
+	})
+
+	Convey("Gofigure should read environment variables from files", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Setenv("FOO_DB_PASSWORD_FILE", "testdata/secrets/DB_PASSWORD")
+		var cfg MyConfigEnvFile
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.DbPassword, ShouldEqual, "hunter2")
+	})
+
+	clear()
+}
+
+// MyConfigDefault is used to test the default tag
+type MyConfigDefault struct {
+	gofigure  interface{} `order:"env,flag"`
+	BindAddr  string      `env:"BIND_ADDR" flag:"bind-addr" default:"localhost:8080" flagDesc:"Bind address"`
+	NumCPU    int         `env:"NUM_CPU" flag:"num-cpu" default:"2"`
+	Enabled   bool        `env:"ENABLED" flag:"enabled" default:"true"`
+	Ratio     float64     `env:"RATIO" flag:"ratio" default:"0.5"`
+	Sources   []string    `env:"SOURCES" flag:"source" default:"a,b,c"`
+	Numbers   []int       `env:"NUMBERS" flag:"number" default:"1,2"`
+	NoDefault string      `env:"NO_DEFAULT" flag:"no-default"`
+}
+
+// MyConfigBadDefault is used to test invalid default tags
+type MyConfigBadDefault struct {
+	gofigure interface{}
+	NumCPU   int `default:"abc"`
+}
+
+func TestDefaultTag(t *testing.T) {
+	Convey("Gofigure should use default values", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigDefault
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.BindAddr, ShouldEqual, "localhost:8080")
+		So(cfg.NumCPU, ShouldEqual, 2)
+		So(cfg.Enabled, ShouldEqual, true)
+		So(cfg.Ratio, ShouldEqual, 0.5)
+		So(cfg.Sources, ShouldResemble, []string{"a", "b", "c"})
+		So(cfg.Numbers, ShouldResemble, []int{1, 2})
+		So(cfg.NoDefault, ShouldEqual, "")
+	})
+
+	Convey("Sources should override default values", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-num-cpu", "4", "-source", "d", "-enabled", "false"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Setenv("BIND_ADDR", "localhost:9090")
+		os.Setenv("NUM_CPU", "3")
+		os.Setenv("NUMBERS", "5")
+		var cfg MyConfigDefault
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.BindAddr, ShouldEqual, "localhost:9090")
+		So(cfg.NumCPU, ShouldEqual, 4)
+		So(cfg.Enabled, ShouldEqual, false)
+		So(cfg.Sources, ShouldResemble, []string{"d"})
+		So(cfg.Numbers, ShouldResemble, []int{5})
+	})
+
+	Convey("Default values should not replace existing values", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		cfg := MyConfigDefault{BindAddr: "existing", Sources: []string{"x"}}
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.BindAddr, ShouldEqual, "existing")
+		So(cfg.Sources, ShouldResemble, []string{"x"})
+	})
+
+	Convey("Default values should be shown in flag help", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigDefault
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(flag.Lookup("bind-addr").DefValue, ShouldEqual, "localhost:8080")
+		So(flag.Lookup("source").DefValue, ShouldEqual, "a, b, c")
+	})
+
+	Convey("Invalid default values should return an error", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigBadDefault
+		err := Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldStartWith, "Invalid default value for field 'NumCPU'")
+	})
+
+	clear()
+}
+
+func TestNestedStructPopulatedOnce(t *testing.T) {
+	Convey("Nested struct slices should only be populated once", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-host", "a", "-host", "b"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg struct {
+			gofigure interface{}
+			Advanced struct {
+				Hosts []string `flag:"host"`
+			}
+		}
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.Advanced.Hosts, ShouldResemble, []string{"a", "b"})
+	})
+
+	clear()
+}
+
+type logLevel int
+
+func (l *logLevel) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "debug":
+		*l = 1
+	case "info":
+		*l = 2
+	default:
+		return fmt.Errorf("Invalid log level '%s'", text)
+	}
+	return nil
+}
+
+type endpoint struct {
+	Host string
+	Port string
+}
+
+func (e *endpoint) UnmarshalText(text []byte) error {
+	parts := strings.SplitN(string(text), ":", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("Invalid endpoint '%s'", text)
+	}
+	e.Host, e.Port = parts[0], parts[1]
+	return nil
+}
+
+func (e endpoint) MarshalText() ([]byte, error) {
+	if len(e.Host) == 0 {
+		return []byte{}, nil
+	}
+	return []byte(e.Host + ":" + e.Port), nil
+}
+
+// MyConfigText is used to test encoding.TextUnmarshaler support
+type MyConfigText struct {
+	gofigure  interface{} `order:"env,flag"`
+	LogLevel  logLevel    `env:"LOG_LEVEL" flag:"log-level" default:"info"`
+	Endpoint  endpoint    `env:"ENDPOINT" flag:"endpoint"`
+	Endpoints []endpoint  `env:"ENDPOINTS" flag:"endpoints"`
+	Levels    []logLevel  `env:"LEVELS" flag:"level"`
+	IP        net.IP      `env:"IP" flag:"ip"`
+	Gateway   net.IP      `env:"GATEWAY" flag:"gateway" default:"10.0.0.1"`
+}
+
+func TestTextUnmarshaler(t *testing.T) {
+	Convey("Gofigure should use UnmarshalText for custom types", t, func() {
+		os.Clearenv()
+		os.Args = []string{
+			"gofigure",
+			"-log-level", "debug",
+			"-endpoints", "a:1",
+			"-endpoints", "b:2",
+			"-level", "info",
+		}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Setenv("ENDPOINT", "localhost:8080")
+		var cfg MyConfigText
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.LogLevel, ShouldEqual, logLevel(1))
+		So(cfg.Endpoint, ShouldResemble, endpoint{"localhost", "8080"})
+		So(cfg.Endpoints, ShouldResemble, []endpoint{{"a", "1"}, {"b", "2"}})
+		So(cfg.Levels, ShouldResemble, []logLevel{2})
+	})
+
+	Convey("Custom types should support defaults and keep existing values", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		cfg := MyConfigText{Endpoint: endpoint{"existing", "1"}}
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.LogLevel, ShouldEqual, logLevel(2))
+		So(cfg.Endpoint, ShouldResemble, endpoint{"existing", "1"})
+	})
+
+	Convey("Slice types implementing UnmarshalText should be set from flags", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-ip", "10.0.0.2"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigText
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.IP.String(), ShouldEqual, "10.0.0.2")
+		So(cfg.Gateway.String(), ShouldEqual, "10.0.0.1")
+		So(flag.Lookup("gateway").DefValue, ShouldEqual, "10.0.0.1")
+	})
+
+	Convey("UnmarshalText errors should be returned", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-log-level", "trace"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigText
+		err := Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "Invalid log level 'trace'")
+	})
+
+	clear()
+}
+
+type myPointerTLS struct {
+	Cert string `env:"TLS_CERT" flag:"tls-cert"`
+	Port int    `env:"TLS_PORT" flag:"tls-port" required:"true"`
+	Mode string `env:"TLS_MODE" flag:"tls-mode" default:"strict"`
+}
+
+// MyConfigPointer is used to test pointer fields
+type MyConfigPointer struct {
+	gofigure interface{} `order:"env,flag"`
+	Workers  *int        `env:"WORKERS" flag:"workers" min:"1"`
+	Verbose  *bool       `env:"VERBOSE" flag:"verbose"`
+	Name     *string     `env:"NAME" flag:"name" default:"app"`
+	Level    *logLevel   `env:"LEVEL" flag:"level"`
+	Hosts    *[]string   `env:"HOSTS" flag:"host"`
+	TLS      *myPointerTLS
+}
+
+func TestPointerFields(t *testing.T) {
+	Convey("Pointer fields should be nil if no value is set", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigPointer
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.Workers, ShouldBeNil)
+		So(cfg.Verbose, ShouldBeNil)
+		So(cfg.Level, ShouldBeNil)
+		So(cfg.Hosts, ShouldBeNil)
+		So(cfg.TLS, ShouldBeNil)
+		So(cfg.Name, ShouldNotBeNil)
+		So(*cfg.Name, ShouldEqual, "app")
+	})
+
+	Convey("Pointer fields should be set to zero values", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-verbose", "false", "-host", "a", "-level", "debug"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Setenv("NAME", "")
+		os.Setenv("TLS_PORT", "443")
+		var cfg MyConfigPointer
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.Workers, ShouldBeNil)
+		So(cfg.Verbose, ShouldNotBeNil)
+		So(*cfg.Verbose, ShouldBeFalse)
+		So(*cfg.Level, ShouldEqual, logLevel(1))
+		So(*cfg.Hosts, ShouldResemble, []string{"a"})
+		So(cfg.TLS, ShouldNotBeNil)
+		So(cfg.TLS.Port, ShouldEqual, 443)
+		So(cfg.TLS.Cert, ShouldEqual, "")
+		So(cfg.TLS.Mode, ShouldEqual, "strict")
+	})
+
+	Convey("Existing pointers should be kept and populated", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-workers", "4"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		workers, name := 2, "existing"
+		tls := &myPointerTLS{Port: 443}
+		cfg := MyConfigPointer{Workers: &workers, Name: &name, TLS: tls}
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.Workers, ShouldEqual, &workers)
+		So(workers, ShouldEqual, 4)
+		So(*cfg.Name, ShouldEqual, "existing")
+		So(cfg.TLS, ShouldEqual, tls)
+		So(tls.Port, ShouldEqual, 443)
+	})
+
+	Convey("Required and validation tags should apply to set pointers", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-workers", "0", "-tls-cert", "cert.pem"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigPointer
+		err := Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "Missing required fields: TLS.Port (env TLS_PORT, flag -tls-port)")
+
+		os.Setenv("TLS_PORT", "443")
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		cfg = MyConfigPointer{}
+		err = Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "Invalid field values: Workers: value 0 violates min=1")
+	})
+
+	clear()
+}
+
+type CommonConfig struct {
+	LogLevel string `json:"log_level" env:"LOG_LEVEL" flag:"log-level"`
+	Port     int    `env:"PORT" flag:"port"`
+}
+
+// MyConfigEmbedded is used to test embedded structs
+type MyConfigEmbedded struct {
+	gofigure interface{} `order:"json,env,flag" jsonPath:"testdata/embedded.json"`
+	CommonConfig
+	BindAddr string `json:"bind_addr" env:"BIND_ADDR" flag:"bind-addr"`
+}
+
+func TestEmbeddedStruct(t *testing.T) {
+	Convey("Embedded struct fields should be flattened", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-port", "8080"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigEmbedded
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.LogLevel, ShouldEqual, "debug")
+		So(cfg.Port, ShouldEqual, 8080)
+		So(cfg.BindAddr, ShouldEqual, "localhost:8080")
+	})
+
+	Convey("Embedded structs with inline:\"false\" should be nested", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg struct {
+			gofigure     interface{} `order:"json" jsonPath:"testdata/embedded.json"`
+			CommonConfig `inline:"false"`
+		}
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.LogLevel, ShouldEqual, "warn")
+	})
+
+	Convey("Key collisions with embedded fields should return an error", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg struct {
+			gofigure interface{}
+			Port     int `env:"PORT" flag:"port"`
+			CommonConfig
+		}
+		err := Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "Embedded field 'CommonConfig.Port' collides with field 'Port' (env PORT)")
+
+		var cfg2 struct {
+			gofigure interface{}
+			Port     int `env:"OUTER_PORT" flag:"outer-port"`
+			CommonConfig
+		}
+		err = Gofigure(&cfg2)
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Invalid inline tags should return an error", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg struct {
+			gofigure     interface{}
+			CommonConfig `inline:"maybe"`
+		}
+		err := Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+	})
+
+	clear()
+}
+
+type nestedPort struct {
+	Port int `env:"PORT" flag:"port"`
+}
+
+func TestNestedPrefixes(t *testing.T) {
+	Convey("Nested params should prefix keys for nested structs", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-backend.port", "8080"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Setenv("FRONTEND_PORT", "80")
+		os.Setenv("ADVANCED_MAX_BYTES", "1024")
+		var cfg struct {
+			gofigure interface{} `envNested:"true" flagNested:"true"`
+			Advanced struct {
+				MaxBytes int `env:"MAX_BYTES" flag:"max-bytes"`
+			}
+			Backend  nestedPort
+			Frontend nestedPort
+		}
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.Advanced.MaxBytes, ShouldEqual, 1024)
+		So(cfg.Backend.Port, ShouldEqual, 8080)
+		So(cfg.Frontend.Port, ShouldEqual, 80)
+	})
+
+	Convey("The flag separator should be configurable", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-advanced-max-bytes", "2048"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg struct {
+			gofigure interface{} `flagNested:"true" flagSeparator:"-"`
+			Advanced struct {
+				MaxBytes int `env:"MAX_BYTES" flag:"max-bytes"`
+			}
+		}
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.Advanced.MaxBytes, ShouldEqual, 2048)
+	})
+
+	Convey("The prefix tag should prefix keys for a nested struct", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-frontend.port", "80"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Setenv("BACKEND_PORT", "8080")
+		os.Setenv("PORT", "1")
+		var cfg struct {
+			gofigure interface{}
+			Backend  nestedPort `prefix:"backend"`
+			Frontend nestedPort `prefix:"frontend"`
+			Admin    struct {
+				Inner nestedPort `prefix:"inner"`
+			} `prefix:"admin"`
+		}
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.Backend.Port, ShouldEqual, 8080)
+		So(cfg.Frontend.Port, ShouldEqual, 80)
+		So(cfg.Admin.Inner.Port, ShouldEqual, 0)
+
+		os.Setenv("ADMIN_INNER_PORT", "9090")
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		err = Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.Admin.Inner.Port, ShouldEqual, 9090)
+	})
+
+	Convey("Nested fields with the same flag should return ErrKeyExists", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg struct {
+			gofigure interface{}
+			Backend  nestedPort
+			Frontend nestedPort
+		}
+		err := Gofigure(&cfg)
+		So(err, ShouldEqual, sources.ErrKeyExists)
+	})
+
+	clear()
+}
+
+// orderSource records the keys registered with it
+type orderSource struct {
+	keys []string
+}
+
+func (o *orderSource) Init(args map[string]string) error { return nil }
+func (o *orderSource) Cleanup()                          {}
+func (o *orderSource) Register(key, defaultValue string, params map[string]string, t reflect.Type) error {
+	o.keys = append(o.keys, key)
+	return nil
+}
+func (o *orderSource) Get(key string, overrideDefault *string) (string, error) {
+	return *overrideDefault, nil
+}
+func (o *orderSource) GetArray(key string, overrideDefault *[]string) ([]string, error) {
+	return *overrideDefault, nil
+}
+
+// lookupSource implements sources.LookupSource, and changes the
+// default value passed to Get
+type lookupSource struct {
+	orderSource
+	values map[string]string
+}
+
+func (l *lookupSource) Get(key string, overrideDefault *string) (string, error) {
+	return strings.ToUpper(*overrideDefault), nil
+}
+func (l *lookupSource) Lookup(key string) (string, bool, error) {
+	v, ok := l.values[key]
+	return v, ok, nil
+}
+
+type orderCommon struct {
+	LogLevel string
+	Debug    bool
+}
+
+// MyConfigOrder is used to test fields are handled in declaration order
+type MyConfigOrder struct {
+	gofigure interface{} `order:"order"`
+	Zebra    string
+	Apple    string
+	orderCommon
+	Nested struct {
+		Yak   int
+		Bison int
+	}
+	Mango []string
+}
+
+func TestFieldOrder(t *testing.T) {
+	Convey("Fields should be registered in declaration order", t, func() {
+		src := &orderSource{}
+		Sources["order"] = src
+		defer delete(Sources, "order")
+		var cfg MyConfigOrder
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(src.keys, ShouldResemble, []string{"Zebra", "Apple", "LogLevel", "Debug", "Yak", "Bison", "Mango"})
+	})
+
+	Convey("Sources which implement LookupSource should be used with Lookup", t, func() {
+		os.Clearenv()
+		src := &lookupSource{values: map[string]string{"Port": "8080"}}
+		var cfg struct {
+			gofigure interface{}
+			Name     *string
+			Port     *int
+		}
+		err := New(WithSource("lookup", src), WithOrder("lookup")).Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.Name, ShouldBeNil)
+		So(*cfg.Port, ShouldEqual, 8080)
+	})
+
+	Convey("The first invalid field should be reported", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		for i := 0; i < 10; i++ {
+			var cfg struct {
+				gofigure interface{}
+				Zebra    int `default:"z"`
+				Apple    int `default:"a"`
+			}
+			err := Gofigure(&cfg)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldStartWith, "Invalid default value for field 'Zebra'")
+		}
+	})
+}
@@ -0,0 +1,42 @@
+package gofigure
+
+import "reflect"
+
+// isIgnored returns true for fields which are never populated,
+// i.e. unexported fields and fields with a gofigure:"-" tag.
+//
+// Unexported embedded structs aren't ignored, since their exported
+// fields can still be set once flattened. They're ignored by
+// parseFields if they have an inline:"false" tag.
+func isIgnored(f reflect.StructField, keys map[string]string) bool {
+	if keys["gofigure"] == "-" {
+		return true
+	}
+	if len(f.PkgPath) == 0 {
+		return false
+	}
+	return !f.Anonymous || f.Type.Kind() != reflect.Struct
+}
+
+// ignored returns true if the item, or the struct or container
+// it's in, has a "-" tag for the source, e.g. env:"-"
+func (gfi *gofiguritem) ignored(source string) bool {
+	if gfi.keys[source] == "-" {
+		return true
+	}
+	if gfi.container != nil {
+		return gfi.container.ignored(source)
+	}
+	return gfi.parent != nil && gfi.parent.item != nil && gfi.parent.item.ignored(source)
+}
+
+// sourceOrder returns the sources in order which aren't ignored for the item
+func (gfi *gofiguritem) sourceOrder(order []string) []string {
+	o := make([]string, 0, len(order))
+	for _, source := range order {
+		if !gfi.ignored(source) {
+			o = append(o, source)
+		}
+	}
+	return o
+}
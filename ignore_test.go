@@ -0,0 +1,101 @@
+package gofigure
+
+import (
+	"flag"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type ignoreCommon struct {
+	LogLevel string `env:"LOG_LEVEL" flag:"log-level"`
+}
+
+type ignoreAdvanced struct {
+	MaxBytes int `env:"MAX_BYTES" flag:"max-bytes"`
+}
+
+// MyConfigIgnore is used to test ignored fields
+type MyConfigIgnore struct {
+	gofigure interface{} `order:"env,flag"`
+	ignoreCommon
+	BindAddr string         `env:"BIND_ADDR" flag:"bind-addr"`
+	Secret   string         `env:"SECRET" flag:"-"`
+	Token    string         `env:"-" flag:"token"`
+	Internal string         `gofigure:"-" env:"INTERNAL" flag:"internal"`
+	Advanced ignoreAdvanced `flag:"-"`
+	cache    map[string]string
+	done     chan struct{}
+	name     string
+}
+
+func TestIgnoredFields(t *testing.T) {
+	Convey("Unexported and ignored fields should be skipped", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-bind-addr", ":8080", "-token", "abc"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Setenv("INTERNAL", "foo")
+		os.Setenv("TOKEN", "def")
+		cfg := MyConfigIgnore{name: "existing"}
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.BindAddr, ShouldEqual, ":8080")
+		So(cfg.Token, ShouldEqual, "abc")
+		So(cfg.Internal, ShouldEqual, "")
+		So(cfg.name, ShouldEqual, "existing")
+		So(cfg.cache, ShouldBeNil)
+		So(flag.CommandLine.Lookup("internal"), ShouldBeNil)
+	})
+
+	Convey("Fields can be excluded from a single source", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Setenv("SECRET", "hunter2")
+		os.Setenv("MAX_BYTES", "1024")
+		var cfg MyConfigIgnore
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.Secret, ShouldEqual, "hunter2")
+		So(cfg.Advanced.MaxBytes, ShouldEqual, 1024)
+		So(flag.CommandLine.Lookup("-"), ShouldBeNil)
+		So(flag.CommandLine.Lookup("max-bytes"), ShouldBeNil)
+		So(flag.CommandLine.Lookup("token"), ShouldNotBeNil)
+	})
+
+	Convey("Fields in unexported embedded structs should be set", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-log-level", "debug"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigIgnore
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.LogLevel, ShouldEqual, "debug")
+	})
+
+	Convey("Unexported embedded structs which aren't inlined should be skipped", t, func() {
+		os.Clearenv()
+		os.Setenv("LOG_LEVEL", "debug")
+		var cfg struct {
+			gofigure     interface{}
+			ignoreCommon `inline:"false"`
+		}
+		err := New(WithFlagSet(nil, []string{})).Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.LogLevel, ShouldEqual, "")
+	})
+
+	Convey("Required fields should only describe sources which aren't ignored", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg struct {
+			gofigure interface{} `order:"env,flag"`
+			Token    string      `env:"-" flag:"token" required:"true"`
+		}
+		err := Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "Missing required fields: Token (flag -token)")
+	})
+}
@@ -0,0 +1,147 @@
+package gofigure
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/ian-kent/gofigure/sources"
+)
+
+// Loader applies configuration using its own sources, order, flag set
+// and logger, so it isn't affected by Sources, DefaultOrder or other
+// Loaders, e.g. when used by a library or in parallel tests.
+//
+// A Loader shouldn't be used by more than one goroutine at a time.
+type Loader struct {
+	sources     map[string]sources.Source
+	order       []string
+	commandLine *sources.CommandLine
+	flagSet     *flag.FlagSet
+	args        []string
+	debug       bool
+	logger      func(message string, args ...interface{})
+}
+
+// Option configures a Loader
+type Option func(*Loader)
+
+// New returns a Loader with new instances of the default sources,
+// using the env and flag sources in that order
+func New(opts ...Option) *Loader {
+	l := &Loader{
+		sources: newSources(),
+		order:   []string{"env", "flag"},
+		debug:   Debug,
+	}
+	l.commandLine = l.sources["flag"].(*sources.CommandLine)
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// WithSource adds a source, or replaces the default source with the same
+// name. If it implements sources.LoggerSource, it logs using the Loader's logger.
+func WithSource(name string, source sources.Source) Option {
+	return func(l *Loader) {
+		l.sources[name] = source
+	}
+}
+
+// WithOrder sets the default source order, which can be
+// overridden using the order tag on the gofigure field
+func WithOrder(order ...string) Option {
+	return func(l *Loader) {
+		l.order = order
+	}
+}
+
+// WithFlagSet sets the flag set and arguments used by the default flag
+// source. If args is nil, os.Args[1:] is used, as it is by default, so
+// flags the Loader doesn't own are errors. Tests can pass an empty
+// slice to ignore the command line, e.g. -test.v.
+//
+// By default, or if flagSet is nil, flags are registered with a new flag
+// set each time Gofigure is called, which returns any error instead of
+// exiting, and lists flags in struct field order in its usage message.
+func WithFlagSet(flagSet *flag.FlagSet, args []string) Option {
+	return func(l *Loader) {
+		l.flagSet = flagSet
+		l.args = args
+	}
+}
+
+// WithLogger sets the function called for each log message,
+// instead of log.Printf
+func WithLogger(logger func(message string, args ...interface{})) Option {
+	return func(l *Loader) {
+		l.logger = logger
+	}
+}
+
+// WithDebug controls log output, which defaults to the value of Debug
+func WithDebug(debug bool) Option {
+	return func(l *Loader) {
+		l.debug = debug
+	}
+}
+
+// defaultLoader returns a Loader using Sources and DefaultOrder
+// and the global flag.CommandLine, which is used by Gofigure
+func defaultLoader() *Loader {
+	return &Loader{
+		sources: Sources,
+		order:   DefaultOrder,
+		debug:   Debug,
+	}
+}
+
+func (l *Loader) printf(message string, args ...interface{}) {
+	if !l.debug {
+		return
+	}
+	if l.logger != nil {
+		l.logger(message, args...)
+	} else {
+		log.Printf(message, args...)
+	}
+}
+
+// isNested returns true if the source supports nested keys
+func (l *Loader) isNested(source string) bool {
+	ns, ok := l.sources[source].(sources.NestedSource)
+	return ok && ns.Nested()
+}
+
+// Gofigure parses and applies the configuration defined by the struct,
+// in the same way as the package level Gofigure function.
+func (l *Loader) Gofigure(s interface{}) error {
+	for _, o := range l.order {
+		if _, ok := l.sources[o]; !ok {
+			return ErrInvalidOrder
+		}
+	}
+
+	for _, source := range l.sources {
+		if ls, ok := source.(sources.LoggerSource); ok {
+			ls.SetLogger(l.printf)
+		}
+	}
+
+	// Flag sources added using WithSource are used as is
+	if l.commandLine != nil && l.sources["flag"] == l.commandLine {
+		l.commandLine.FlagSet = l.flagSet
+		l.commandLine.Args = l.args
+		if l.flagSet == nil {
+			l.commandLine.FlagSet = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+			l.commandLine.FlagSet.Usage = l.commandLine.Usage
+		}
+	}
+
+	gfg, err := l.parseStruct(s)
+	if err != nil {
+		return err
+	}
+	return gfg.apply(nil)
+}
@@ -0,0 +1,192 @@
+package gofigure
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/ian-kent/gofigure/sources"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// MyConfigLoader is used to test Loaders
+type MyConfigLoader struct {
+	gofigure interface{}
+	BindAddr string   `env:"BIND_ADDR" flag:"bind-addr"`
+	Sources  []string `env:"SOURCES" flag:"source"`
+}
+
+func TestLoader(t *testing.T) {
+	Convey("Loaders should use their own flag set", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-bind-addr", ":8080", "-source", "a"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+
+		var cfg1, cfg2 MyConfigLoader
+		So(New().Gofigure(&cfg1), ShouldBeNil)
+		So(New().Gofigure(&cfg2), ShouldBeNil)
+		So(cfg1.BindAddr, ShouldEqual, ":8080")
+		So(cfg1.Sources, ShouldResemble, []string{"a"})
+		So(cfg2, ShouldResemble, cfg1)
+		So(flag.Lookup("bind-addr"), ShouldBeNil)
+	})
+
+	Convey("Loaders should parse os.Args by default", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-bind-addr", ":8080"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+
+		var cfg MyConfigLoader
+		So(New().Gofigure(&cfg), ShouldBeNil)
+		So(cfg.BindAddr, ShouldEqual, ":8080")
+	})
+
+	Convey("Flags the Loader doesn't own should return an error unless ignored", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-other", "1", "-bind-addr", ":8080"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+
+		var cfg MyConfigLoader
+		err := New().Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "flag provided but not defined: -other")
+
+		cfg = MyConfigLoader{}
+		So(New(WithFlagSet(nil, []string{})).Gofigure(&cfg), ShouldBeNil)
+		So(cfg.BindAddr, ShouldBeEmpty)
+	})
+
+	Convey("Loaders can be used more than once", t, func() {
+		os.Clearenv()
+		os.Setenv("BIND_ADDR", ":9090")
+		l := New(WithFlagSet(nil, []string{}))
+		var cfg1, cfg2 MyConfigLoader
+		So(l.Gofigure(&cfg1), ShouldBeNil)
+		So(l.Gofigure(&cfg2), ShouldBeNil)
+		So(cfg1.BindAddr, ShouldEqual, ":9090")
+		So(cfg2.BindAddr, ShouldEqual, ":9090")
+	})
+
+	Convey("WithFlagSet should set the flag set and arguments", t, func() {
+		os.Clearenv()
+		fs := flag.NewFlagSet("app", flag.ContinueOnError)
+		var cfg MyConfigLoader
+		err := New(WithFlagSet(fs, []string{"-source", "b", "-source", "c"})).Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.Sources, ShouldResemble, []string{"b", "c"})
+		So(fs.Lookup("source"), ShouldNotBeNil)
+	})
+
+	Convey("Flag parse errors should be returned", t, func() {
+		os.Clearenv()
+		fs := flag.NewFlagSet("app", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		var cfg MyConfigLoader
+		err := New(WithFlagSet(fs, []string{"-unknown"})).Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("WithOrder and WithSource should only affect the Loader", t, func() {
+		os.Clearenv()
+		os.Setenv("BIND_ADDR", ":9090")
+		src := &orderSource{}
+		var cfg MyConfigLoader
+		err := New(WithSource("order", src), WithOrder("order", "env")).Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.BindAddr, ShouldEqual, ":9090")
+		So(src.keys, ShouldResemble, []string{"BindAddr", "Sources"})
+		_, ok := Sources["order"]
+		So(ok, ShouldBeFalse)
+		So(DefaultOrder, ShouldResemble, []string{"env", "flag"})
+	})
+
+	Convey("Loaders should have their own source instances", t, func() {
+		l1, l2 := New(), New()
+		So(l1.sources["env"], ShouldNotPointTo, l2.sources["env"])
+		So(l1.sources["env"], ShouldNotPointTo, Sources["env"])
+	})
+
+	Convey("An invalid order should return an error", t, func() {
+		var cfg MyConfigLoader
+		err := New(WithOrder("env", "missing")).Gofigure(&cfg)
+		So(err, ShouldEqual, ErrInvalidOrder)
+	})
+
+	Convey("WithLogger should receive log messages", t, func() {
+		os.Clearenv()
+		var messages []string
+		logger := func(message string, args ...interface{}) {
+			messages = append(messages, fmt.Sprintf(message, args...))
+		}
+		var cfg MyConfigLoader
+		err := New(WithFlagSet(nil, []string{}), WithDebug(true), WithLogger(logger)).Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(messages, ShouldContain, "Parsed field 'BindAddr'")
+
+		messages = nil
+		err = New(WithFlagSet(nil, []string{}), WithDebug(false), WithLogger(logger)).Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(messages, ShouldBeEmpty)
+	})
+
+	Convey("Sources and type conversions should use the Loader's logger", t, func() {
+		os.Clearenv()
+		defer func(debug bool) {
+			Debug = debug
+			sources.Debug = debug
+			log.SetOutput(os.Stderr)
+		}(Debug)
+		Debug = true
+		sources.Debug = true
+		var out bytes.Buffer
+		log.SetOutput(&out)
+
+		var messages []string
+		logger := func(message string, args ...interface{}) {
+			messages = append(messages, fmt.Sprintf(message, args...))
+		}
+		args := []string{"-bind-addr", ":8080", "-source", "a"}
+		var cfg MyConfigLoader
+		err := New(WithFlagSet(nil, args), WithDebug(true), WithLogger(logger)).Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(messages, ShouldContain, "Looking up key 'bind-addr'")
+		So(messages, ShouldContain, "Set called for arrayValue: a")
+		So(messages, ShouldContain, "Appending string value 'a' to slice")
+
+		messages = nil
+		err = New(WithFlagSet(nil, args), WithDebug(false), WithLogger(logger)).Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(messages, ShouldBeEmpty)
+		So(out.String(), ShouldBeEmpty)
+	})
+
+	Convey("Loader flag sets should list flags in struct field order", t, func() {
+		os.Clearenv()
+		l := New(WithFlagSet(nil, []string{}))
+		var cfg struct {
+			gofigure interface{}
+			Zebra    string `flag:"zebra" flagDesc:"Zebra"`
+			Apple    string `flag:"apple" flagDesc:"Apple"`
+		}
+		So(l.Gofigure(&cfg), ShouldBeNil)
+
+		var out bytes.Buffer
+		l.commandLine.FlagSet.SetOutput(&out)
+		l.commandLine.FlagSet.Usage()
+		So(out.String(), ShouldEqual, "Usage of "+os.Args[0]+":\n  -zebra string\n    \tZebra\n  -apple string\n    \tApple\n")
+	})
+
+	Convey("Custom flag sources should be used as is", t, func() {
+		os.Clearenv()
+		fs := flag.NewFlagSet("app", flag.ContinueOnError)
+		cl := &sources.CommandLine{FlagSet: fs, Args: []string{"-bind-addr", ":7070"}}
+		var cfg MyConfigLoader
+		err := New(WithSource("flag", cl)).Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.BindAddr, ShouldEqual, ":7070")
+	})
+}
@@ -0,0 +1,236 @@
+package gofigure
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/ian-kent/gofigure/sources"
+)
+
+// isStructMap returns true for maps with struct values, e.g.
+// map[string]SubStruct, which are populated like nested structs
+func isStructMap(t reflect.Type) bool {
+	return t.Kind() == reflect.Map && t.Elem().Kind() == reflect.Struct && !isTextUnmarshaler(t.Elem())
+}
+
+// merge returns false if the merge tag is false, in which case
+// the last source with a value replaces the map instead of
+// being merged with values from earlier sources
+func (gfi *gofiguritem) merge() (bool, error) {
+	m, ok := gfi.keys["merge"]
+	if !ok {
+		return true, nil
+	}
+	b, err := strconv.ParseBool(m)
+	if err != nil {
+		return false, fmt.Errorf("Invalid merge tag for field '%s': %s", gfi.path(), err)
+	}
+	return b, nil
+}
+
+// getMap returns the map value for key from a source, using GetArray
+// with values in key=value format if it doesn't implement MapSource
+func getMap(source sources.Source, key string) (map[string]string, error) {
+	if ms, ok := source.(sources.MapSource); ok {
+		return ms.GetMap(key)
+	}
+	vals, err := source.GetArray(key, &[]string{})
+	if err != nil {
+		return nil, err
+	}
+	return sources.ParseMap(vals)
+}
+
+// mapKey converts k to the key type of the map v
+func mapKey(v reflect.Value, k string, printf func(message string, args ...interface{})) (reflect.Value, error) {
+	key := reflect.New(v.Type().Key()).Elem()
+	err := setDefaultType(key, k, nil, printf)
+	if err != nil {
+		return key, fmt.Errorf("Invalid map key '%s': %s", k, err)
+	}
+	return key, nil
+}
+
+// setMapType converts each of val to the key and element
+// types of the map v and sets it
+func setMapType(v reflect.Value, val map[string]string, keys map[string]string, printf func(message string, args ...interface{})) error {
+	if len(val) > 0 && v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+	for k, s := range val {
+		printf("Setting %s value '%s' for map key '%s'", v.Type().Elem(), s, k)
+		key, err := mapKey(v, k, printf)
+		if err != nil {
+			return err
+		}
+		e := reflect.New(v.Type().Elem()).Elem()
+		err = setDefaultType(e, s, keys, printf)
+		if err != nil {
+			return err
+		}
+		v.SetMapIndex(key, e)
+	}
+	return nil
+}
+
+func (gfi *gofiguritem) populateMapType(order []string) error {
+	if isStructMap(gfi.goValue.Type()) {
+		return gfi.populateStructMapType()
+	}
+
+	merge, err := gfi.merge()
+	if err != nil {
+		return err
+	}
+
+	val := make(map[string]string)
+	for _, source := range order {
+		kn := gfi.key(source)
+
+		gfi.printf("Looking for field '%s' with key '%s' in source '%s'", gfi.field, kn, source)
+		m, err := getMap(gfi.source(source), kn)
+		if err != nil {
+			return err
+		}
+		if len(m) == 0 {
+			continue
+		}
+
+		gfi.printf("Got value '%+v' from map source '%s' for key '%s'", m, source, gfi.field)
+
+		gfi.set = true
+		if !merge {
+			val = make(map[string]string)
+		}
+		for k, v := range m {
+			val[k] = v
+		}
+	}
+
+	if !gfi.set && gfi.goValue.Len() == 0 {
+		if def, ok := gfi.keys["default"]; ok {
+			gfi.printf("Using default value '%s' for field '%s'", def, gfi.field)
+			val, err = sources.ParseMap(splitDefault(def))
+			if err != nil {
+				return err
+			}
+			gfi.set = true
+			gfi.defaulted = true
+		}
+	}
+
+	if gfi.set && !merge {
+		gfi.goValue.Set(reflect.MakeMap(gfi.goValue.Type()))
+	}
+
+	return setMapType(gfi.goValue, val, gfi.keys, gfi.printf)
+}
+
+// mapKeys returns the keys of a map of structs from each source,
+// or from the last source with any keys if merge is false
+func (gfi *gofiguritem) mapKeys(order []string, merge bool) ([]string, error) {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, source := range order {
+		ks, ok := gfi.source(source).(sources.KeySource)
+		if !ok {
+			continue
+		}
+		k, err := ks.Keys(gfi.key(source))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid value for field '%s' in source '%s': %s", gfi.path(), source, err)
+		}
+		if len(k) > 0 && !merge {
+			keys = k
+			continue
+		}
+		for _, key := range k {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
+
+// registerStructMap registers a nested struct for each key of
+// a map of structs. Values are only read from nested sources.
+func (gfi *gofiguritem) registerStructMap(gfg *gofiguration) error {
+	var order []string
+	for _, o := range gfi.sourceOrder(gfg.order) {
+		if gfg.loader.isNested(o) {
+			order = append(order, o)
+		}
+	}
+
+	merge, err := gfi.merge()
+	if err != nil {
+		return err
+	}
+	keys, err := gfi.mapKeys(order, merge)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		key, err := mapKey(gfi.goValue, k, gfi.printf)
+		if err != nil {
+			return fmt.Errorf("Invalid value for field '%s': %s", gfi.path(), err)
+		}
+
+		// Existing values are only kept if merging
+		ev := reflect.New(gfi.goValue.Type().Elem()).Elem()
+		if merge && !gfi.goValue.IsNil() {
+			if cur := gfi.goValue.MapIndex(key); cur.IsValid() {
+				ev.Set(cur)
+			}
+		}
+
+		egfi := &gofiguritem{
+			field:     k,
+			keys:      make(map[string]string),
+			goValue:   ev,
+			parent:    gfg,
+			container: gfi,
+		}
+		sGfg, err := gfg.loader.parseStruct(ev)
+		if err != nil {
+			return err
+		}
+		sGfg.order = order
+		sGfg.params = gfg.params
+		sGfg.item = egfi
+		egfi.inner = sGfg
+		err = sGfg.apply(gfg)
+		if err != nil {
+			return err
+		}
+		gfi.elems = append(gfi.elems, egfi)
+	}
+
+	return nil
+}
+
+func (gfi *gofiguritem) populateStructMapType() error {
+	merge, err := gfi.merge()
+	if err != nil {
+		return err
+	}
+	if len(gfi.elems) > 0 && (!merge || gfi.goValue.IsNil()) {
+		gfi.goValue.Set(reflect.MakeMap(gfi.goValue.Type()))
+	}
+
+	for _, e := range gfi.elems {
+		err := e.populateStructType(nil)
+		if err != nil {
+			return err
+		}
+		key, _ := mapKey(gfi.goValue, e.field, gfi.printf)
+		gfi.goValue.SetMapIndex(key, e.goValue)
+		gfi.set = true
+	}
+
+	return nil
+}
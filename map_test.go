@@ -0,0 +1,128 @@
+package gofigure
+
+import (
+	"flag"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type mapUpstream struct {
+	Host string `json:"host" required:"true"`
+	Port int    `json:"port" default:"80"`
+}
+
+// MyConfigMap is used to test map fields
+type MyConfigMap struct {
+	gofigure  interface{}            `order:"json,env,flag" jsonPath:"testdata/maps.json"`
+	Labels    map[string]string      `json:"labels" env:"LABELS" flag:"label"`
+	Limits    map[string]int         `json:"limits" env:"LIMITS" flag:"limit" merge:"false"`
+	Weights   map[string]float64     `env:"WEIGHTS" flag:"weight" default:"a=0.5,b=1.5"`
+	Upstreams map[string]mapUpstream `json:"upstreams"`
+}
+
+func TestMapFields(t *testing.T) {
+	Convey("Gofigure should read maps from files", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigMap
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.Labels, ShouldResemble, map[string]string{"app": "gofigure", "tier": "web"})
+		So(cfg.Limits, ShouldResemble, map[string]int{"cpu": 2, "memory": 512})
+		So(cfg.Weights, ShouldResemble, map[string]float64{"a": 0.5, "b": 1.5})
+		So(cfg.Upstreams, ShouldResemble, map[string]mapUpstream{
+			"primary":   {"a.local", 8080},
+			"secondary": {"b.local", 80},
+		})
+	})
+
+	Convey("Maps should be merged or replaced by later sources", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-label", "tier=db", "-limit", "cpu=4", "-weight", "c=2"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Setenv("LABELS", "region=eu,tier=cache")
+		os.Setenv("LABELS_OWNER", "ops")
+		var cfg MyConfigMap
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.Labels, ShouldResemble, map[string]string{
+			"app":    "gofigure",
+			"tier":   "db",
+			"region": "eu",
+			"owner":  "ops",
+		})
+		So(cfg.Limits, ShouldResemble, map[string]int{"cpu": 4})
+		So(cfg.Weights, ShouldResemble, map[string]float64{"c": 2})
+	})
+
+	Convey("Variables for other fields shouldn't be map keys", t, func() {
+		os.Clearenv()
+		os.Setenv("LABELS_TIER", "db")
+		os.Setenv("LABELS_EXTRA", "z")
+		var cfg struct {
+			gofigure    interface{}
+			Labels      map[string]string
+			LabelsExtra string
+		}
+		err := New(WithFlagSet(nil, []string{})).Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.Labels, ShouldResemble, map[string]string{"tier": "db"})
+		So(cfg.LabelsExtra, ShouldEqual, "z")
+	})
+
+	Convey("Existing map values should be kept unless replaced", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		cfg := MyConfigMap{
+			Labels:    map[string]string{"existing": "1"},
+			Limits:    map[string]int{"existing": 1},
+			Weights:   map[string]float64{"existing": 1},
+			Upstreams: map[string]mapUpstream{"primary": {"old.local", 9090}, "other": {"c.local", 80}},
+		}
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.Labels, ShouldResemble, map[string]string{"existing": "1", "app": "gofigure", "tier": "web"})
+		So(cfg.Limits, ShouldResemble, map[string]int{"cpu": 2, "memory": 512})
+		So(cfg.Weights, ShouldResemble, map[string]float64{"existing": 1})
+		So(cfg.Upstreams, ShouldResemble, map[string]mapUpstream{
+			"primary":   {"a.local", 8080},
+			"secondary": {"b.local", 80},
+			"other":     {"c.local", 80},
+		})
+	})
+
+	Convey("Invalid map values should return an error", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-limit", "cpu"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigMap
+		err := Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "Invalid map value 'cpu', expected key=value")
+
+		os.Args = []string{"gofigure", "-limit", "cpu=lots"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		cfg = MyConfigMap{}
+		err = Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Map of struct fields should be checked for required values", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg struct {
+			gofigure  interface{}            `order:"json" jsonPath:"testdata/maps.json"`
+			Upstreams map[string]mapUpstream `json:"limits"`
+		}
+		err := Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "Missing required fields: Upstreams.cpu.Host (json limits.cpu.host); Upstreams.memory.Host (json limits.memory.host)")
+	})
+
+	clear()
+}
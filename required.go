@@ -0,0 +1,127 @@
+package gofigure
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ian-kent/gofigure/sources"
+)
+
+// MissingFieldsError is returned when fields tagged with
+// required:"true" have no value after all sources are applied
+type MissingFieldsError struct {
+	Fields []MissingField
+}
+
+// MissingField describes a required field which has no value
+type MissingField struct {
+	// Path is the fully qualified field name, e.g. Advanced.MaxBytes
+	Path string
+	// Keys describes where the value could have been set,
+	// in source order, e.g. env MAX_BYTES and flag -max-bytes
+	Keys []SourceKey
+}
+
+// SourceKey describes where a value can be set for a source
+type SourceKey struct {
+	Source string
+	Key    string
+}
+
+func (e *MissingFieldsError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		keys := make([]string, 0, len(f.Keys))
+		for _, k := range f.Keys {
+			keys = append(keys, k.Source+" "+k.Key)
+		}
+		fields = append(fields, fmt.Sprintf("%s (%s)", f.Path, strings.Join(keys, ", ")))
+	}
+	return "Missing required fields: " + strings.Join(fields, "; ")
+}
+
+// path returns the fully qualified field name, e.g. Advanced.MaxBytes
+func (gfi *gofiguritem) path() string {
+	if gfi.container != nil {
+		return gfi.container.path() + "." + gfi.field
+	}
+	if gfi.parent != nil && gfi.parent.item != nil {
+		return gfi.parent.item.path() + "." + gfi.field
+	}
+	return gfi.field
+}
+
+// describe returns where the value for the item can be set
+func (gfi *gofiguritem) describe(order []string) []SourceKey {
+	keys := make([]SourceKey, 0, len(order))
+	for _, o := range order {
+		key := gfi.key(o)
+		if d, ok := gfi.source(o).(sources.Describer); ok {
+			key = d.Describe(key)
+		}
+		keys = append(keys, SourceKey{o, key})
+	}
+	return keys
+}
+
+// checkRequired returns a MissingFieldsError listing every
+// required field which still has its zero value, in the order
+// the fields are declared
+func (gfg *gofiguration) checkRequired() error {
+	var missing []MissingField
+	err := gfg.findMissing(&missing)
+	if err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	return &MissingFieldsError{missing}
+}
+
+func (gfg *gofiguration) findMissing(missing *[]MissingField) error {
+	for _, gfi := range gfg.fields {
+		if gfi.inner != nil {
+			// Fields in nil pointers to structs aren't required
+			if gfi.isNil() {
+				continue
+			}
+			err := gfi.inner.findMissing(missing)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		for _, e := range gfi.elems {
+			err := e.inner.findMissing(missing)
+			if err != nil {
+				return err
+			}
+		}
+
+		req, ok := gfi.keys["required"]
+		if !ok {
+			continue
+		}
+		b, err := strconv.ParseBool(req)
+		if err != nil {
+			return fmt.Errorf("Invalid required tag for field '%s': %s", gfi.path(), err)
+		}
+		if !b {
+			continue
+		}
+
+		// Explicit zero values, e.g. -port 0, are set
+		empty := !gfi.set && gfi.isZero()
+		if !gfi.goPtr.IsValid() && (gfi.goValue.Kind() == reflect.Slice || gfi.goValue.Kind() == reflect.Map) {
+			empty = gfi.goValue.Len() == 0
+		}
+		if empty {
+			*missing = append(*missing, MissingField{gfi.path(), gfi.describe(gfi.sourceOrder(gfg.order))})
+		}
+	}
+	return nil
+}
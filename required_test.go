@@ -0,0 +1,113 @@
+package gofigure
+
+import (
+	"flag"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// MyConfigRequired is used to test the required tag
+type MyConfigRequired struct {
+	gofigure    interface{} `envPrefix:"FOO" order:"env,flag"`
+	DatabaseURL string      `env:"DATABASE_URL" flag:"database-url" required:"true"`
+	Sources     []string    `env:"SOURCES" flag:"source" required:"true"`
+	Optional    string      `env:"OPTIONAL" flag:"optional" required:"false"`
+	Advanced    struct {
+		MaxBytes int64 `env:"MAX_BYTES" flag:"max-bytes" required:"true"`
+	}
+}
+
+// MyConfigRequiredDefault is used to test required fields with defaults
+type MyConfigRequiredDefault struct {
+	gofigure interface{}
+	BindAddr string `required:"true" default:"localhost:8080"`
+}
+
+// MyConfigBadRequired is used to test invalid required tags
+type MyConfigBadRequired struct {
+	gofigure interface{}
+	BindAddr string `required:"maybe"`
+}
+
+// MyConfigRequiredZero is used to test required fields set to zero values
+type MyConfigRequiredZero struct {
+	gofigure interface{}
+	Port     int  `env:"PORT" flag:"port" required:"true"`
+	Debug    bool `env:"DEBUG" flag:"debug" required:"true"`
+}
+
+func TestRequired(t *testing.T) {
+	Convey("Required fields explicitly set to zero values should not be missing", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-port", "0"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Setenv("DEBUG", "false")
+		var cfg MyConfigRequiredZero
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.Port, ShouldEqual, 0)
+		So(cfg.Debug, ShouldBeFalse)
+
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		err = Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "Missing required fields: Port (env PORT, flag -port); Debug (env DEBUG, flag -debug)")
+	})
+
+	Convey("Gofigure should return all missing required fields", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigRequired
+		err := Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+		mfe, ok := err.(*MissingFieldsError)
+		So(ok, ShouldBeTrue)
+		So(mfe.Fields, ShouldResemble, []MissingField{
+			{"DatabaseURL", []SourceKey{{"env", "FOO_DATABASE_URL"}, {"flag", "-database-url"}}},
+			{"Sources", []SourceKey{{"env", "FOO_SOURCES"}, {"flag", "-source"}}},
+			{"Advanced.MaxBytes", []SourceKey{{"env", "FOO_MAX_BYTES"}, {"flag", "-max-bytes"}}},
+		})
+		So(err.Error(), ShouldEqual, "Missing required fields: "+
+			"DatabaseURL (env FOO_DATABASE_URL, flag -database-url); "+
+			"Sources (env FOO_SOURCES, flag -source); "+
+			"Advanced.MaxBytes (env FOO_MAX_BYTES, flag -max-bytes)")
+	})
+
+	Convey("Gofigure should succeed when required fields are set", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-max-bytes", "10", "-source", "a"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Setenv("FOO_DATABASE_URL", "postgres://localhost")
+		var cfg MyConfigRequired
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.DatabaseURL, ShouldEqual, "postgres://localhost")
+	})
+
+	Convey("Default values should satisfy required fields", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigRequiredDefault
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.BindAddr, ShouldEqual, "localhost:8080")
+	})
+
+	Convey("Invalid required tags should return an error", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigBadRequired
+		err := Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldStartWith, "Invalid required tag for field 'BindAddr'")
+	})
+
+	clear()
+}
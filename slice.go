@@ -0,0 +1,116 @@
+package gofigure
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/ian-kent/gofigure/sources"
+)
+
+// isStructSlice returns true for slices with struct elements, e.g.
+// []SubStruct, which are populated like nested structs
+func isStructSlice(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct && !isTextUnmarshaler(t.Elem())
+}
+
+// inElement returns true if the item is in a map or slice of structs,
+// in which case keys are always nested, e.g. UPSTREAMS_0_HOST
+func (gfi *gofiguritem) inElement() bool {
+	if gfi.container != nil {
+		return true
+	}
+	return gfi.parent != nil && gfi.parent.item != nil && gfi.parent.item.inElement()
+}
+
+// sliceIndexes returns the indexes of a slice of structs from each source
+func (gfi *gofiguritem) sliceIndexes(order []string) ([]int, error) {
+	var indexes []int
+	seen := make(map[int]bool)
+	for _, source := range order {
+		ks, ok := gfi.source(source).(sources.KeySource)
+		if !ok {
+			continue
+		}
+		keys, err := ks.Keys(gfi.key(source))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid value for field '%s' in source '%s': %s", gfi.path(), source, err)
+		}
+		for _, k := range keys {
+			i, err := strconv.Atoi(k)
+			if err != nil || i < 0 || seen[i] {
+				continue
+			}
+			seen[i] = true
+			indexes = append(indexes, i)
+		}
+	}
+	sort.Ints(indexes)
+	return indexes, nil
+}
+
+// registerStructSlice registers a nested struct for each element of
+// a slice of structs, growing the slice to fit the indexes in any source
+func (gfi *gofiguritem) registerStructSlice(gfg *gofiguration) error {
+	indexes, err := gfi.sliceIndexes(gfi.sourceOrder(gfg.order))
+	if err != nil {
+		return err
+	}
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	// Indexes must be contiguous, except for existing elements
+	present := make(map[int]bool)
+	for _, i := range indexes {
+		present[i] = true
+	}
+	n := indexes[len(indexes)-1] + 1
+	for i := gfi.goValue.Len(); i < n; i++ {
+		if !present[i] {
+			return fmt.Errorf("Missing index %d for field '%s'", i, gfi.path())
+		}
+	}
+	if n > gfi.goValue.Len() {
+		s := reflect.MakeSlice(gfi.goValue.Type(), n, n)
+		reflect.Copy(s, gfi.goValue)
+		gfi.goValue.Set(s)
+	}
+
+	for _, i := range indexes {
+		egfi := &gofiguritem{
+			field:     strconv.Itoa(i),
+			keys:      make(map[string]string),
+			goValue:   gfi.goValue.Index(i),
+			parent:    gfg,
+			container: gfi,
+		}
+		sGfg, err := gfg.loader.parseStruct(egfi.goValue)
+		if err != nil {
+			return err
+		}
+		sGfg.order = gfg.order
+		sGfg.params = gfg.params
+		sGfg.item = egfi
+		egfi.inner = sGfg
+		err = sGfg.apply(gfg)
+		if err != nil {
+			return err
+		}
+		gfi.elems = append(gfi.elems, egfi)
+	}
+
+	return nil
+}
+
+func (gfi *gofiguritem) populateStructSliceType() error {
+	for _, e := range gfi.elems {
+		err := e.populateStructType(nil)
+		if err != nil {
+			return err
+		}
+		gfi.set = true
+	}
+	return nil
+}
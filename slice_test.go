@@ -0,0 +1,110 @@
+package gofigure
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type sliceUpstream struct {
+	Host   string `yaml:"host" env:"HOST" flag:"host" required:"true"`
+	Port   int    `yaml:"port" env:"PORT" flag:"port" default:"80"`
+	Weight int    `yaml:"weight" env:"WEIGHT" flag:"weight" max:"10"`
+}
+
+func (u *sliceUpstream) Validate() error {
+	if u.Host == "invalid" {
+		return errors.New("invalid host")
+	}
+	return nil
+}
+
+// MyConfigSlice is used to test slices of structs
+type MyConfigSlice struct {
+	gofigure  interface{}     `order:"yaml,env,flag" yamlPath:"testdata/upstreams.yaml"`
+	Upstreams []sliceUpstream `yaml:"upstreams" env:"UPSTREAMS" flag:"upstreams"`
+}
+
+func TestStructSliceField(t *testing.T) {
+	Convey("Slices of structs should be read from files", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigSlice
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.Upstreams, ShouldResemble, []sliceUpstream{
+			{"a.local", 8080, 0},
+			{"b.local", 80, 2},
+		})
+	})
+
+	Convey("Slices of structs should be read from indexed env vars and flags", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-upstreams.1.port", "9090", "-upstreams.2.host", "c.local"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Setenv("UPSTREAMS_0_HOST", "env.local")
+		os.Setenv("UPSTREAMS_2_WEIGHT", "5")
+		var cfg MyConfigSlice
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.Upstreams, ShouldResemble, []sliceUpstream{
+			{"env.local", 8080, 0},
+			{"b.local", 9090, 2},
+			{"c.local", 80, 5},
+		})
+	})
+
+	Convey("Existing elements should be kept", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		cfg := MyConfigSlice{Upstreams: []sliceUpstream{{"x.local", 1, 1}, {"y.local", 2, 2}, {"z.local", 3, 3}}}
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.Upstreams, ShouldResemble, []sliceUpstream{
+			{"a.local", 8080, 1},
+			{"b.local", 2, 2},
+			{"z.local", 3, 3},
+		})
+	})
+
+	Convey("Missing indexes should return an error", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-upstreams.3.host", "d.local"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigSlice
+		err := Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "Missing index 2 for field 'Upstreams'")
+	})
+
+	Convey("Elements should be checked for required and valid values", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-upstreams.2.port", "81"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigSlice
+		err := Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "Missing required fields: Upstreams.2.Host (yaml upstreams.2.host, env UPSTREAMS_2_HOST, flag -upstreams.2.host)")
+
+		os.Args = []string{"gofigure", "-upstreams.1.weight", "11"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		cfg = MyConfigSlice{}
+		err = Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "Invalid field values: Upstreams.1.Weight: value 11 violates max=10")
+
+		os.Args = []string{"gofigure", "-upstreams.1.host", "invalid"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		cfg = MyConfigSlice{}
+		err = Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "Upstreams.1: invalid host")
+	})
+
+	clear()
+}
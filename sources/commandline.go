@@ -2,6 +2,8 @@ package sources
 
 import (
 	"flag"
+	"fmt"
+	"os"
 	"reflect"
 	"regexp"
 	"strings"
@@ -10,130 +12,367 @@ import (
 var flagRe1 = regexp.MustCompile("(.)([A-Z][a-z]+)")
 var flagRe2 = regexp.MustCompile("([a-z0-9])([A-Z])")
 
+// camelToFlag converts a key to a flag name. Each part of a nested
+// key, e.g. Upstreams.0.Host, is converted separately, e.g. upstreams.0.host.
 func camelToFlag(camel string) (flag string) {
-	flag = flagRe1.ReplaceAllString(camel, "${1}-${2}")
-	flag = flagRe2.ReplaceAllString(flag, "${1}-${2}")
-	return strings.ToLower(flag)
+	parts := strings.Split(camel, KeySeparator)
+	for i, p := range parts {
+		p = flagRe1.ReplaceAllString(p, "${1}-${2}")
+		p = flagRe2.ReplaceAllString(p, "${1}-${2}")
+		parts[i] = strings.ToLower(p)
+	}
+	return strings.Join(parts, KeySeparator)
 }
 
 // CommandLine implements command line configuration using the flag package
+//
+// If the nested param is true, flags for fields in nested structs are
+// prefixed with the parent field, joined using the separator param,
+// which defaults to KeySeparator, e.g. -advanced.max-bytes
 type CommandLine struct {
+	// FlagSet is used to register and parse flags. If nil,
+	// flag.CommandLine is used.
+	FlagSet *flag.FlagSet
+	// Args are the arguments parsed by FlagSet. If nil,
+	// os.Args[1:] is used.
+	Args []string
+
+	logging
 	flags      map[string]*string
 	arrayFlags map[string]*arrayValue
+	names      []string
 	oldCl      *flag.FlagSet
+	nested     bool
+	separator  string
 }
 
-type arrayValue []string
+// arrayValue implements flag.Value for slices. The defaults
+// are only used for help output.
+type arrayValue struct {
+	values   []string
+	defaults []string
+	printf   func(message string, args ...interface{})
+}
 
 func (aV *arrayValue) Set(value string) error {
-	printf("Set called for arrayValue: %s", value)
-	if *aV == nil {
-		*aV = make([]string, 0, 1)
-	}
-	*aV = append(*aV, value)
+	aV.printf("Set called for arrayValue: %s", value)
+	aV.values = append(aV.values, value)
 	return nil
 }
 
 func (aV *arrayValue) String() string {
-	return strings.Join(*aV, ", ")
+	if aV == nil {
+		return ""
+	}
+	if len(aV.values) > 0 {
+		return strings.Join(aV.values, ", ")
+	}
+	return strings.Join(aV.defaults, ", ")
+}
+
+// flagSet returns the flag set used to register and parse flags
+func (cl *CommandLine) flagSet() *flag.FlagSet {
+	if cl.FlagSet != nil {
+		return cl.FlagSet
+	}
+	return flag.CommandLine
+}
+
+// args returns the arguments to parse
+func (cl *CommandLine) args() []string {
+	if cl.Args != nil {
+		return cl.Args
+	}
+	return os.Args[1:]
+}
+
+// parse parses the arguments if they haven't already been parsed
+func (cl *CommandLine) parse() error {
+	fs := cl.flagSet()
+	if fs.Parsed() {
+		return nil
+	}
+	return fs.Parse(cl.args())
+}
+
+// isSet returns true if the flag was given on the command line
+func (cl *CommandLine) isSet(name string) bool {
+	set := false
+	cl.flagSet().Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
 }
 
 // Init is called at the start of a new struct
 func (cl *CommandLine) Init(args map[string]string) error {
 	cl.flags = make(map[string]*string)
 	cl.arrayFlags = make(map[string]*arrayValue)
-	cl.oldCl = flag.CommandLine
-	// flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	cl.names = nil
+	if cl.FlagSet == nil {
+		cl.oldCl = flag.CommandLine
+		// flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	}
+	cl.separator = KeySeparator
+	cl.nested = false
+
+	if sep, ok := args["separator"]; ok && len(sep) > 0 {
+		cl.separator = sep
+	}
+	nested, err := parseNested(args)
+	if err != nil {
+		return err
+	}
+	cl.nested = nested
 	return nil
 }
 
+// Nested returns true if the nested param is set
+func (cl *CommandLine) Nested() bool {
+	return cl.nested
+}
+
+// flagName returns the flag name for a key, with the
+// parts of nested keys joined using the separator
+func (cl *CommandLine) flagName(key string) string {
+	name := camelToFlag(key)
+	if cl.separator != KeySeparator {
+		name = strings.Replace(name, KeySeparator, cl.separator, -1)
+	}
+	return name
+}
+
 // Cleanup is called at the end of parsing
 func (cl *CommandLine) Cleanup() {
-	flag.CommandLine = cl.oldCl
+	if cl.FlagSet == nil {
+		flag.CommandLine = cl.oldCl
+	}
 }
 
 // Register is called to register each struct field
 func (cl *CommandLine) Register(key, defaultValue string, params map[string]string, t reflect.Type) error {
-	if _, ok := cl.flags[key]; ok {
+	key = cl.flagName(key)
+	_, isFlag := cl.flags[key]
+	_, isArrayFlag := cl.arrayFlags[key]
+	if isFlag || isArrayFlag || cl.flagSet().Lookup(key) != nil {
 		return ErrKeyExists
 	}
-	key = camelToFlag(key)
 
 	// TODO validate key?
 	// TODO use typed calls instead of StringVar
-	printf("Got type %s", t.Kind())
+	cl.printf("Got type %s", t.Kind())
 	switch t.Kind() {
-	case reflect.Slice:
-		printf("Registering slice type for %s", key)
-		var val arrayValue
+	case reflect.Slice, reflect.Array, reflect.Map:
+		cl.printf("Registering %s type for %s", t.Kind(), key)
+		val := arrayValue{printf: cl.printf}
 		if len(defaultValue) > 0 {
-			val = append(val, defaultValue)
+			val.defaults = strings.Split(defaultValue, ",")
 		}
 		cl.arrayFlags[key] = &val
 
 		// TODO validate description in some way?
 		desc := params["flagDesc"]
 
-		flag.Var(&val, key, desc)
+		cl.flagSet().Var(&val, key, desc)
 	default:
-		printf("Registering default type for %s", key)
+		cl.printf("Registering default type for %s", key)
 		val := defaultValue
 		cl.flags[key] = &val
 
 		// TODO validate description in some way?
 		desc := params["flagDesc"]
 
-		flag.StringVar(&val, key, defaultValue, desc)
+		cl.flagSet().StringVar(&val, key, defaultValue, desc)
 	}
+	cl.names = append(cl.names, key)
 
 	return nil
 }
 
 // Get is called to retrieve a key value
 func (cl *CommandLine) Get(key string, overrideDefault *string) (string, error) {
-	key = camelToFlag(key)
-	printf("Looking up key '%s'", key)
+	key = cl.flagName(key)
+	cl.printf("Looking up key '%s'", key)
 
-	if !flag.CommandLine.Parsed() {
-		flag.Parse()
+	err := cl.parse()
+	if err != nil {
+		return "", err
 	}
-	// TODO check if flag exists/overrideDefault
 	val := ""
-	if v, ok := cl.flags[key]; ok {
-		printf("Found flag value '%s'", *v)
+	v, ok := cl.flags[key]
+	if ok && cl.isSet(key) {
+		cl.printf("Found flag value '%s'", *v)
 		val = *v
 	}
 	if len(val) > 0 {
-		printf("Returning val '%s'", val)
+		cl.printf("Returning val '%s'", val)
 		return val, nil
 	}
 	if overrideDefault != nil {
-		printf("Returning overrideDefault '%s'", *overrideDefault)
+		cl.printf("Returning overrideDefault '%s'", *overrideDefault)
 		return *overrideDefault, nil
 	}
+	if ok {
+		cl.printf("Returning default '%s'", *v)
+		return *v, nil
+	}
 	return "", nil
 }
 
+// Lookup is called to retrieve a key value, and returns
+// false if the flag wasn't given or is empty
+func (cl *CommandLine) Lookup(key string) (string, bool, error) {
+	key = cl.flagName(key)
+	cl.printf("Looking up key '%s'", key)
+
+	err := cl.parse()
+	if err != nil {
+		return "", false, err
+	}
+	v, ok := cl.flags[key]
+	if !ok || !cl.isSet(key) || len(*v) == 0 {
+		return "", false, nil
+	}
+	return *v, true, nil
+}
+
+// Describe returns the flag for a key
+func (cl *CommandLine) Describe(key string) string {
+	return "-" + cl.flagName(key)
+}
+
+// Keys is called to retrieve the keys of a map or slice of structs,
+// from the command line arguments, e.g. -upstreams.0.host. Flags for
+// each key must be registered before the command line is parsed.
+func (cl *CommandLine) Keys(key string) ([]string, error) {
+	prefix := cl.flagName(key) + cl.separator
+	var keys []string
+	seen := make(map[string]bool)
+	for _, arg := range cl.args() {
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		if i := strings.Index(name, "="); i > -1 {
+			name = name[:i]
+		}
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest := name[len(prefix):]
+		i := strings.Index(rest, cl.separator)
+		if i < 1 {
+			continue
+		}
+		if k := rest[:i]; !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+// GetArray is called to retrieve an array value
 func (cl *CommandLine) GetArray(key string, overrideDefault *[]string) ([]string, error) {
-	key = camelToFlag(key)
-	printf("Looking up array key '%s'", key)
+	key = cl.flagName(key)
+	cl.printf("Looking up array key '%s'", key)
 
-	if !flag.CommandLine.Parsed() {
-		flag.Parse()
+	err := cl.parse()
+	if err != nil {
+		return nil, err
 	}
-	// TODO check if flag exists/overrideDefault
 	val := []string{}
-	if v, ok := cl.arrayFlags[key]; ok {
-		printf("Found flag value '%s'", *v)
-		val = *v
+	v, ok := cl.arrayFlags[key]
+	if ok && len(v.values) > 0 {
+		cl.printf("Found flag value '%s'", v)
+		val = v.values
 	}
 	if len(val) > 0 {
-		printf("Returning val '%s'", val)
+		cl.printf("Returning val '%s'", val)
 		return val, nil
 	}
 	if overrideDefault != nil {
-		printf("Returning overrideDefault '%s'", *overrideDefault)
+		cl.printf("Returning overrideDefault '%s'", *overrideDefault)
 		return *overrideDefault, nil
 	}
+	if ok && len(v.defaults) > 0 {
+		cl.printf("Returning defaults '%s'", v.defaults)
+		return v.defaults, nil
+	}
 	return val, nil
 }
+
+// PrintDefaults prints the registered flags in the order they were
+// registered, i.e. struct field order, in the same format as
+// flag.PrintDefaults. Other flags in the flag set are printed
+// afterwards in alphabetical order.
+func (cl *CommandLine) PrintDefaults() {
+	fs := cl.flagSet()
+	seen := make(map[string]bool)
+	for _, name := range cl.names {
+		if f := fs.Lookup(name); f != nil {
+			seen[name] = true
+			printFlag(fs, f)
+		}
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		if !seen[f.Name] {
+			printFlag(fs, f)
+		}
+	})
+}
+
+// Usage prints a usage message followed by PrintDefaults, and
+// can be used as the Usage function of the flag set
+func (cl *CommandLine) Usage() {
+	fs := cl.flagSet()
+	if len(fs.Name()) == 0 {
+		fmt.Fprintf(fs.Output(), "Usage:\n")
+	} else {
+		fmt.Fprintf(fs.Output(), "Usage of %s:\n", fs.Name())
+	}
+	cl.PrintDefaults()
+}
+
+// printFlag prints a flag in the same format as flag.PrintDefaults
+func printFlag(fs *flag.FlagSet, f *flag.Flag) {
+	s := "  -" + f.Name
+	name, usage := flag.UnquoteUsage(f)
+	if len(name) > 0 {
+		s += " " + name
+	}
+	// Single character flags without a type fit on one line
+	if len(s) <= 4 {
+		s += "\t"
+	} else {
+		s += "\n    \t"
+	}
+	s += strings.Replace(usage, "\n", "\n    \t", -1)
+	if !isZeroValue(f) {
+		if reflect.TypeOf(f.Value).String() == "*flag.stringValue" {
+			s += fmt.Sprintf(" (default %q)", f.DefValue)
+		} else {
+			s += fmt.Sprintf(" (default %v)", f.DefValue)
+		}
+	}
+	fmt.Fprintln(fs.Output(), s)
+}
+
+// isZeroValue returns true if the default is the zero value
+// for the flag type, which flag.PrintDefaults doesn't print
+func isZeroValue(f *flag.Flag) bool {
+	t := reflect.TypeOf(f.Value)
+	var z reflect.Value
+	if t.Kind() == reflect.Ptr {
+		z = reflect.New(t.Elem())
+	} else {
+		z = reflect.Zero(t)
+	}
+	v, ok := z.Interface().(flag.Value)
+	return ok && f.DefValue == v.String()
+}
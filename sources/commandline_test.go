@@ -1,6 +1,13 @@
 package sources
 
 import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -11,5 +18,137 @@ func TestCamelToFlag(t *testing.T) {
 		So(camelToFlag("CamelCase"), ShouldEqual, "camel-case")
 		So(camelToFlag("camelCase"), ShouldEqual, "camel-case")
 		So(camelToFlag("CaMeLCase"), ShouldEqual, "ca-me-l-case")
+		So(camelToFlag("Upstreams.0.MaxConns"), ShouldEqual, "upstreams.0.max-conns")
+	})
+}
+
+func TestCommandLineKeys(t *testing.T) {
+	Convey("Keys returns the keys of indexed flags", t, func() {
+		args := os.Args
+		defer func() { os.Args = args }()
+		os.Args = []string{"gofigure", "-upstreams.1.host", "b", "--upstreams.0.host=a", "-upstreams.0.port", "80", "-upstreams", "x", "--", "-upstreams.2.host"}
+
+		cl := &CommandLine{}
+		So(cl.Init(map[string]string{}), ShouldBeNil)
+		k, err := cl.Keys("Upstreams")
+		So(err, ShouldBeNil)
+		So(k, ShouldResemble, []string{"1", "0"})
+
+		k, err = cl.Keys("Missing")
+		So(err, ShouldBeNil)
+		So(k, ShouldBeNil)
+	})
+}
+
+func TestCommandLineNested(t *testing.T) {
+	Convey("Nested and separator params are used for nested keys", t, func() {
+		args := os.Args
+		defer func() { os.Args = args }()
+		os.Args = []string{"gofigure", "-upstreams-0-host", "a"}
+
+		cl := &CommandLine{}
+		So(cl.Init(map[string]string{}), ShouldBeNil)
+		So(cl.Nested(), ShouldBeFalse)
+		So(cl.Describe("Advanced.MaxBytes"), ShouldEqual, "-advanced.max-bytes")
+
+		cl = &CommandLine{}
+		So(cl.Init(map[string]string{"nested": "true", "separator": "-"}), ShouldBeNil)
+		So(cl.Nested(), ShouldBeTrue)
+		So(cl.Describe("Advanced.MaxBytes"), ShouldEqual, "-advanced-max-bytes")
+
+		k, err := cl.Keys("Upstreams")
+		So(err, ShouldBeNil)
+		So(k, ShouldResemble, []string{"0"})
+
+		So(cl.Init(map[string]string{"nested": "maybe"}), ShouldNotBeNil)
+	})
+}
+
+func TestCommandLineFlagSet(t *testing.T) {
+	Convey("FlagSet and Args are used instead of the global flag set", t, func() {
+		fs := flag.NewFlagSet("gofigure", flag.ContinueOnError)
+		cl := &CommandLine{FlagSet: fs, Args: []string{"-bind-addr", ":8080", "-source", "a", "-upstreams.0.host", "b"}}
+		So(cl.Init(map[string]string{}), ShouldBeNil)
+		So(cl.Register("BindAddr", "", map[string]string{}, reflect.TypeOf("")), ShouldBeNil)
+		So(cl.Register("Source", "", map[string]string{}, reflect.TypeOf([]string{})), ShouldBeNil)
+		So(cl.Register("Upstreams.0.Host", "", map[string]string{}, reflect.TypeOf("")), ShouldBeNil)
+		So(fs.Lookup("bind-addr"), ShouldNotBeNil)
+		So(flag.Lookup("bind-addr"), ShouldBeNil)
+
+		v, err := cl.Get("BindAddr", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, ":8080")
+		a, err := cl.GetArray("Source", nil)
+		So(err, ShouldBeNil)
+		So(a, ShouldResemble, []string{"a"})
+		k, err := cl.Keys("Upstreams")
+		So(err, ShouldBeNil)
+		So(k, ShouldResemble, []string{"0"})
+		cl.Cleanup()
+	})
+
+	Convey("Parse errors are returned", t, func() {
+		fs := flag.NewFlagSet("gofigure", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		cl := &CommandLine{FlagSet: fs, Args: []string{"-unknown"}}
+		So(cl.Init(map[string]string{}), ShouldBeNil)
+		_, err := cl.Get("BindAddr", nil)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestCommandLineKeyExists(t *testing.T) {
+	Convey("Register returns ErrKeyExists for duplicate flags", t, func() {
+		fs := flag.NewFlagSet("gofigure", flag.ContinueOnError)
+		cl := &CommandLine{FlagSet: fs, Args: []string{}}
+		So(cl.Init(map[string]string{}), ShouldBeNil)
+		So(cl.Register("Port", "", map[string]string{}, reflect.TypeOf("")), ShouldBeNil)
+		So(cl.Register("Port", "", map[string]string{}, reflect.TypeOf("")), ShouldEqual, ErrKeyExists)
+		So(cl.Register("port", "", map[string]string{}, reflect.TypeOf([]string{})), ShouldEqual, ErrKeyExists)
+
+		So(cl.Register("Hosts", "", map[string]string{}, reflect.TypeOf([]string{})), ShouldBeNil)
+		So(cl.Register("Hosts", "", map[string]string{}, reflect.TypeOf("")), ShouldEqual, ErrKeyExists)
+
+		fs.String("verbose", "", "")
+		So(cl.Register("Verbose", "", map[string]string{}, reflect.TypeOf("")), ShouldEqual, ErrKeyExists)
+	})
+}
+
+// flagBlocks splits PrintDefaults output into a sorted block per flag
+func flagBlocks(s string) []string {
+	blocks := strings.Split("\n"+strings.TrimRight(s, "\n"), "\n  -")[1:]
+	sort.Strings(blocks)
+	return blocks
+}
+
+func TestCommandLinePrintDefaults(t *testing.T) {
+	Convey("PrintDefaults prints flags in registration order", t, func() {
+		var out bytes.Buffer
+		fs := flag.NewFlagSet("app", flag.ContinueOnError)
+		fs.SetOutput(&out)
+		fs.Bool("verbose", false, "Verbose output")
+		fs.Int("workers", 4, "Number of workers")
+
+		cl := &CommandLine{FlagSet: fs, Args: []string{}}
+		So(cl.Init(map[string]string{}), ShouldBeNil)
+		So(cl.Register("Zebra", "", map[string]string{"flagDesc": "Zebra `name`"}, reflect.TypeOf("")), ShouldBeNil)
+		So(cl.Register("Apple", "red", map[string]string{"flagDesc": "Apple colour"}, reflect.TypeOf("")), ShouldBeNil)
+		So(cl.Register("Hosts", "a,b", map[string]string{"flagDesc": "Host (can be\nprovided multiple times)"}, reflect.TypeOf([]string{})), ShouldBeNil)
+
+		cl.Usage()
+		So(out.String(), ShouldEqual, "Usage of app:\n"+
+			"  -zebra name\n    \tZebra name\n"+
+			"  -apple string\n    \tApple colour (default \"red\")\n"+
+			"  -hosts value\n    \tHost (can be\n    \tprovided multiple times) (default a, b)\n"+
+			"  -verbose\n    \tVerbose output\n"+
+			"  -workers int\n    \tNumber of workers (default 4)\n")
+
+		// Each flag should be formatted the same as flag.PrintDefaults
+		var ordered, sorted bytes.Buffer
+		fs.SetOutput(&ordered)
+		cl.PrintDefaults()
+		fs.SetOutput(&sorted)
+		fs.PrintDefaults()
+		So(flagBlocks(ordered.String()), ShouldResemble, flagBlocks(sorted.String()))
 	})
 }
@@ -0,0 +1,108 @@
+package sources
+
+import (
+	"errors"
+	"mime"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownFormat is returned when no decoder is registered
+// for a format, file extension or MIME type
+var ErrUnknownFormat = errors.New("Unknown format")
+
+// Decoder decodes a structured document, e.g. JSON or YAML
+type Decoder interface {
+	Decode(b []byte) (map[string]interface{}, error)
+}
+
+// DecodeFunc implements Decoder using a function
+type DecodeFunc func(b []byte) (map[string]interface{}, error)
+
+// Decode calls f(b)
+func (f DecodeFunc) Decode(b []byte) (map[string]interface{}, error) {
+	return f(b)
+}
+
+var decoders = struct {
+	sync.RWMutex
+	formats    map[string]Decoder
+	extensions map[string]Decoder
+	mimeTypes  map[string]Decoder
+}{
+	formats:    make(map[string]Decoder),
+	extensions: make(map[string]Decoder),
+	mimeTypes:  make(map[string]Decoder),
+}
+
+func init() {
+	RegisterDecoder("json", DecodeFunc(decodeJSON), []string{".json"}, []string{"application/json", "text/json"})
+	RegisterDecoder("yaml", DecodeFunc(decodeYAML), []string{".yaml", ".yml"}, []string{"application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml"})
+	RegisterDecoder("toml", DecodeFunc(decodeTOML), []string{".toml"}, []string{"application/toml"})
+	RegisterDecoder("ini", DecodeFunc(decodeINI), []string{".ini"}, nil)
+	RegisterDecoder("properties", DecodeFunc(decodeProperties), []string{".properties"}, nil)
+}
+
+// RegisterDecoder registers a Decoder with a format name, e.g. json,
+// and the file extensions and MIME types it should be used for.
+//
+// Registering an existing format, extension or MIME type
+// replaces the previous decoder.
+func RegisterDecoder(format string, d Decoder, extensions []string, mimeTypes []string) {
+	decoders.Lock()
+	defer decoders.Unlock()
+
+	decoders.formats[strings.ToLower(format)] = d
+	for _, e := range extensions {
+		if !strings.HasPrefix(e, ".") {
+			e = "." + e
+		}
+		decoders.extensions[strings.ToLower(e)] = d
+	}
+	for _, m := range mimeTypes {
+		decoders.mimeTypes[strings.ToLower(m)] = d
+	}
+}
+
+// DecoderFor returns the Decoder for a format name if given, otherwise
+// for a MIME type, e.g. from a Content-Type header, or the extension of
+// a file path or URL.
+//
+// MIME types with a structured syntax suffix, e.g. application/vnd.foo+json,
+// use the decoder registered for the suffix.
+//
+// It returns ErrUnknownFormat if no decoder is found.
+func DecoderFor(format, path, contentType string) (Decoder, error) {
+	decoders.RLock()
+	defer decoders.RUnlock()
+
+	if len(format) > 0 {
+		if d, ok := decoders.formats[strings.ToLower(format)]; ok {
+			return d, nil
+		}
+		return nil, ErrUnknownFormat
+	}
+
+	if len(contentType) > 0 {
+		if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+			if d, ok := decoders.mimeTypes[mt]; ok {
+				return d, nil
+			}
+			if i := strings.LastIndex(mt, "+"); i > -1 {
+				if d, ok := decoders.formats[mt[i+1:]]; ok {
+					return d, nil
+				}
+			}
+		}
+	}
+
+	if i := strings.IndexAny(path, "?#"); i > -1 {
+		path = path[:i]
+	}
+	if d, ok := decoders.extensions[strings.ToLower(filepath.Ext(path))]; ok {
+		return d, nil
+	}
+
+	return nil, ErrUnknownFormat
+}
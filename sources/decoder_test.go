@@ -0,0 +1,58 @@
+package sources
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDecoderFor(t *testing.T) {
+	Convey("DecoderFor finds decoders by format", t, func() {
+		d, err := DecoderFor("JSON", "", "")
+		So(err, ShouldBeNil)
+		data, err := d.Decode([]byte(`{"a":"b"}`))
+		So(err, ShouldBeNil)
+		So(data["a"], ShouldEqual, "b")
+
+		_, err = DecoderFor("xml", "config.json", "")
+		So(err, ShouldEqual, ErrUnknownFormat)
+	})
+
+	Convey("DecoderFor finds decoders by MIME type", t, func() {
+		d, err := DecoderFor("", "", "text/yaml; charset=utf-8")
+		So(err, ShouldBeNil)
+		data, err := d.Decode([]byte("a: b"))
+		So(err, ShouldBeNil)
+		So(data["a"], ShouldEqual, "b")
+
+		d, err = DecoderFor("", "", "application/vnd.gofigure+json")
+		So(err, ShouldBeNil)
+		data, err = d.Decode([]byte(`{"a":"b"}`))
+		So(err, ShouldBeNil)
+		So(data["a"], ShouldEqual, "b")
+	})
+
+	Convey("DecoderFor finds decoders by extension", t, func() {
+		for _, p := range []string{"a.json", "a.YAML", "a.yml", "a.toml", "a.ini", "a.properties", "http://host/a.json?v=1"} {
+			_, err := DecoderFor("", p, "")
+			So(err, ShouldBeNil)
+		}
+
+		_, err := DecoderFor("", "a.xml", "text/plain")
+		So(err, ShouldEqual, ErrUnknownFormat)
+	})
+
+	Convey("RegisterDecoder registers new formats", t, func() {
+		RegisterDecoder("test", DecodeFunc(func(b []byte) (map[string]interface{}, error) {
+			return map[string]interface{}{"content": string(b)}, nil
+		}), []string{"tst"}, []string{"application/x-test"})
+
+		for _, d := range [][]string{{"test", "", ""}, {"", "a.tst", ""}, {"", "", "application/x-test"}} {
+			dec, err := DecoderFor(d[0], d[1], d[2])
+			So(err, ShouldBeNil)
+			data, err := dec.Decode([]byte("abc"))
+			So(err, ShouldBeNil)
+			So(data["content"], ShouldEqual, "abc")
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package sources
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// Dir implements configuration using a directory containing one
+// file per value, e.g. Kubernetes secret volumes or /run/secrets
+//
+// The file name is the dir tag if set, otherwise the key converted to
+// snake case, e.g. DB_PASSWORD, falling back to lower case, e.g. db_password.
+// Trailing newlines are removed, and missing files are treated as unset.
+type Dir struct {
+	logging
+
+	path   string
+	fields map[string]string
+	files  map[string]string
+}
+
+// Init is called at the start of a new struct
+func (d *Dir) Init(args map[string]string) error {
+	path, ok := args["path"]
+	if !ok || len(path) == 0 {
+		return ErrNoPath
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return &os.PathError{Op: "open", Path: path, Err: ErrNotDirectory}
+	}
+
+	d.path = path
+	d.fields = make(map[string]string)
+	d.files = make(map[string]string)
+	return nil
+}
+
+// Register is called to register each struct field
+func (d *Dir) Register(key, defaultValue string, params map[string]string, t reflect.Type) error {
+	d.fields[key] = defaultValue
+	if name, ok := params["dir"]; ok && len(name) > 0 {
+		d.files[key] = name
+	}
+	return nil
+}
+
+// read returns the contents of the file for key
+func (d *Dir) read(key string) (string, bool, error) {
+	names := []string{camelToSnake(key), strings.ToLower(camelToSnake(key))}
+	if name, ok := d.files[key]; ok {
+		names = []string{name}
+	}
+
+	for _, name := range names {
+		p := filepath.Join(d.path, name)
+		d.printf("Looking for file '%s'", p)
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return "", false, err
+		}
+		return strings.TrimRight(string(b), "\r\n"), true, nil
+	}
+
+	return "", false, nil
+}
+
+// Describe returns the file path for a key
+func (d *Dir) Describe(key string) string {
+	if name, ok := d.files[key]; ok {
+		return filepath.Join(d.path, name)
+	}
+	return filepath.Join(d.path, camelToSnake(key))
+}
+
+// Lookup is called to retrieve a key value, and
+// returns false if the file doesn't exist
+func (d *Dir) Lookup(key string) (string, bool, error) {
+	return d.read(key)
+}
+
+// Get is called to retrieve a key value
+func (d *Dir) Get(key string, overrideDefault *string) (string, error) {
+	val, ok, err := d.read(key)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return val, nil
+	}
+	if overrideDefault != nil {
+		return *overrideDefault, nil
+	}
+	return d.fields[key], nil
+}
+
+// GetArray is called to retrieve an array value, with
+// one value per line in the file
+func (d *Dir) GetArray(key string, overrideDefault *[]string) ([]string, error) {
+	val, ok, err := d.read(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if overrideDefault != nil {
+			return *overrideDefault, nil
+		}
+		return []string{}, nil
+	}
+
+	arr := []string{}
+	for _, line := range strings.Split(val, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if len(line) > 0 {
+			arr = append(arr, line)
+		}
+	}
+	return arr, nil
+}
+
+// Cleanup is called at the end of parsing
+func (d *Dir) Cleanup() {
+
+}
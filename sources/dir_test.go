@@ -0,0 +1,67 @@
+package sources
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDir(t *testing.T) {
+	Convey("Init returns an error without a path", t, func() {
+		d := &Dir{}
+		So(d.Init(map[string]string{}), ShouldEqual, ErrNoPath)
+	})
+
+	Convey("Init returns an error if the path isn't a directory", t, func() {
+		d := &Dir{}
+		So(d.Init(map[string]string{"path": "testdata/missing"}), ShouldNotBeNil)
+		So(d.Init(map[string]string{"path": "testdata/config.json"}), ShouldNotBeNil)
+	})
+
+	Convey("Dir reads values from files", t, func() {
+		d := &Dir{}
+		So(d.Init(map[string]string{"path": "testdata/secrets"}), ShouldBeNil)
+		So(d.Register("CustomKey", "", map[string]string{"dir": "custom-name"}, reflect.TypeOf("")), ShouldBeNil)
+
+		v, err := d.Get("DbPassword", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "hunter2")
+
+		v, err = d.Get("ApiKey", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "lower")
+
+		v, err = d.Get("CustomKey", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "custom")
+	})
+
+	Convey("Dir treats missing files as unset", t, func() {
+		d := &Dir{}
+		So(d.Init(map[string]string{"path": "testdata/secrets"}), ShouldBeNil)
+		So(d.Register("Missing", "default", nil, reflect.TypeOf("")), ShouldBeNil)
+
+		v, err := d.Get("Missing", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "default")
+
+		od := "override"
+		v, err = d.Get("Missing", &od)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "override")
+
+		a, err := d.GetArray("Missing", nil)
+		So(err, ShouldBeNil)
+		So(a, ShouldResemble, []string{})
+	})
+
+	Convey("Dir reads arrays with one value per line", t, func() {
+		d := &Dir{}
+		So(d.Init(map[string]string{"path": "testdata/secrets"}), ShouldBeNil)
+
+		a, err := d.GetArray("Hosts", nil)
+		So(err, ShouldBeNil)
+		So(a, ShouldResemble, []string{"alpha", "beta"})
+	})
+}
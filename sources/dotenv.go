@@ -0,0 +1,265 @@
+package sources
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// DotEnv implements configuration using .env files
+//
+// Keys are derived in the same way as Environment, and the prefix, infix
+// and nested params have the same meaning. Multiple files can be given as a
+// comma separated path, with values in later files overriding earlier ones.
+type DotEnv struct {
+	logging
+
+	prefix        string
+	infix         string
+	fields        map[string]string
+	values        map[string]string
+	supportArrays bool
+	nested        bool
+}
+
+// Init is called at the start of a new struct
+func (de *DotEnv) Init(args map[string]string) error {
+	de.infix = "_"
+	de.prefix = ""
+	de.fields = make(map[string]string)
+	de.values = make(map[string]string)
+
+	if prefix, ok := args["prefix"]; ok {
+		de.prefix = prefix
+	}
+	if infix, ok := args["infix"]; ok {
+		de.infix = infix
+	}
+	nested, err := parseNested(args)
+	if err != nil {
+		return err
+	}
+	de.nested = nested
+
+	if v := os.Getenv("GOFIGURE_ENV_ARRAY"); v == "1" || strings.ToLower(v) == "true" || strings.ToLower(v) == "y" {
+		de.supportArrays = true
+	}
+
+	path, ok := args["path"]
+	if !ok || len(path) == 0 {
+		return ErrNoPath
+	}
+
+	for _, p := range strings.Split(path, ",") {
+		p = strings.TrimSpace(p)
+		de.printf("Reading .env file '%s'", p)
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		vals, err := parseDotEnv(string(b))
+		if err != nil {
+			return fmt.Errorf("%s: %s", p, err)
+		}
+		for k, v := range vals {
+			de.values[k] = v
+		}
+	}
+
+	return nil
+}
+
+// Nested returns true if the nested param is set
+func (de *DotEnv) Nested() bool {
+	return de.nested
+}
+
+// Register is called to register each struct field
+func (de *DotEnv) Register(key, defaultValue string, params map[string]string, t reflect.Type) error {
+	de.fields[camelToSnake(key)] = defaultValue
+	return nil
+}
+
+// Get is called to retrieve a key value
+func (de *DotEnv) Get(key string, overrideDefault *string) (string, error) {
+	def := de.fields[camelToSnake(key)]
+	if overrideDefault != nil {
+		def = *overrideDefault
+	}
+	if v, ok, _ := de.Lookup(key); ok {
+		return v, nil
+	}
+	return def, nil
+}
+
+// Lookup is called to retrieve a key value, and returns
+// false if the variable isn't set or is empty
+func (de *DotEnv) Lookup(key string) (string, bool, error) {
+	v := de.values[de.Describe(key)]
+	return v, len(v) > 0, nil
+}
+
+// Describe returns the variable name for a key
+func (de *DotEnv) Describe(key string) string {
+	key = camelToSnake(key)
+	if len(de.prefix) > 0 {
+		return de.prefix + de.infix + key
+	}
+	return key
+}
+
+// GetArray is called to retrieve an array value
+func (de *DotEnv) GetArray(key string, overrideDefault *[]string) ([]string, error) {
+	var oD *string
+	if overrideDefault != nil {
+		ovr := ""
+		if len(*overrideDefault) > 0 {
+			ovr = (*overrideDefault)[0]
+		}
+		oD = &ovr
+	}
+	v, e := de.Get(key, oD)
+	arr := []string{v}
+
+	if de.supportArrays {
+		if strings.Contains(v, ",") {
+			arr = strings.Split(v, ",")
+		}
+	}
+
+	if len(v) > 0 {
+		return arr, e
+	}
+	return []string{}, e
+}
+
+// GetMap is called to retrieve a map value, in the same
+// formats supported by Environment
+func (de *DotEnv) GetMap(key string) (map[string]string, error) {
+	eK := de.Describe(key)
+	exclude := make(map[string]bool)
+	for k := range de.fields {
+		if n := de.Describe(k); n != eK {
+			exclude[n] = true
+		}
+	}
+	return mapFromVars(de.values, eK, exclude)
+}
+
+// Keys is called to retrieve the keys of a map or slice of
+// structs, in the same format supported by Environment
+func (de *DotEnv) Keys(key string) ([]string, error) {
+	names := make([]string, 0, len(de.values))
+	for n := range de.values {
+		names = append(names, n)
+	}
+	return keysFromVars(names, de.Describe(key)), nil
+}
+
+// Cleanup is called at the end of parsing
+func (de *DotEnv) Cleanup() {
+
+}
+
+// parseDotEnv parses the contents of a .env file.
+//
+// Lines are in KEY=value format, optionally prefixed with export.
+// Single quoted values are literal, double quoted values support
+// escapes and can span multiple lines. Unquoted values are trimmed
+// and can be followed by a comment.
+func parseDotEnv(content string) (map[string]string, error) {
+	vals := make(map[string]string)
+	lines := strings.Split(strings.Replace(content, "\r\n", "\n", -1), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(lines[i])
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		if strings.HasPrefix(line, "export ") {
+			line = strings.TrimSpace(line[len("export "):])
+		}
+
+		eq := strings.Index(line, "=")
+		if eq < 1 {
+			return nil, fmt.Errorf("line %d: expected KEY=value", lineNo)
+		}
+		key := strings.TrimSpace(line[:eq])
+		if strings.ContainsAny(key, " \t'\"") {
+			return nil, fmt.Errorf("line %d: invalid key '%s'", lineNo, key)
+		}
+		val := strings.TrimSpace(line[eq+1:])
+
+		if len(val) > 0 && (val[0] == '"' || val[0] == '\'') {
+			q := val[0]
+			val = val[1:]
+			for {
+				end := closingQuote(val, q)
+				if end > -1 {
+					rest := strings.TrimSpace(val[end+1:])
+					if len(rest) > 0 && rest[0] != '#' {
+						return nil, fmt.Errorf("line %d: unexpected characters after quoted value", i+1)
+					}
+					val = val[:end]
+					break
+				}
+				i++
+				if i >= len(lines) {
+					return nil, fmt.Errorf("line %d: unterminated quoted value", lineNo)
+				}
+				val += "\n" + lines[i]
+			}
+			if q == '"' {
+				val = unescapeDotEnv(val)
+			}
+		} else if idx := strings.Index(val, " #"); idx > -1 {
+			val = strings.TrimSpace(val[:idx])
+		}
+
+		vals[key] = val
+	}
+
+	return vals, nil
+}
+
+// closingQuote returns the index of the closing quote q in s,
+// skipping escaped characters in double quoted values
+func closingQuote(s string, q byte) int {
+	for i := 0; i < len(s); i++ {
+		if q == '"' && s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == q {
+			return i
+		}
+	}
+	return -1
+}
+
+func unescapeDotEnv(s string) string {
+	var out []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			out = append(out, s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case '"', '\\', '$', '\'':
+			out = append(out, s[i])
+		default:
+			out = append(out, '\\', s[i])
+		}
+	}
+	return string(out)
+}
@@ -0,0 +1,125 @@
+package sources
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseDotEnv(t *testing.T) {
+	Convey("parseDotEnv parses simple values", t, func() {
+		v, err := parseDotEnv("FOO=bar\nBAZ = qux \n\n# comment\nexport EXPORTED=yes\nEMPTY=\n")
+		So(err, ShouldBeNil)
+		So(v, ShouldResemble, map[string]string{
+			"FOO":      "bar",
+			"BAZ":      "qux",
+			"EXPORTED": "yes",
+			"EMPTY":    "",
+		})
+	})
+
+	Convey("parseDotEnv strips inline comments from unquoted values", t, func() {
+		v, err := parseDotEnv("FOO=bar # comment\nURL=http://host/#anchor")
+		So(err, ShouldBeNil)
+		So(v["FOO"], ShouldEqual, "bar")
+		So(v["URL"], ShouldEqual, "http://host/#anchor")
+	})
+
+	Convey("parseDotEnv handles quoted values", t, func() {
+		v, err := parseDotEnv(`SINGLE='a # b \n'
+DOUBLE="a # b\n\t\"c\"" # comment
+`)
+		So(err, ShouldBeNil)
+		So(v["SINGLE"], ShouldEqual, `a # b \n`)
+		So(v["DOUBLE"], ShouldEqual, "a # b\n\t\"c\"")
+	})
+
+	Convey("parseDotEnv handles multi-line values", t, func() {
+		v, err := parseDotEnv("KEY=\"-----BEGIN-----\nabc\n-----END-----\"\nNEXT=1\r\n")
+		So(err, ShouldBeNil)
+		So(v["KEY"], ShouldEqual, "-----BEGIN-----\nabc\n-----END-----")
+		So(v["NEXT"], ShouldEqual, "1")
+	})
+
+	Convey("parseDotEnv returns errors for invalid input", t, func() {
+		_, err := parseDotEnv("FOO")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "line 1: expected KEY=value")
+
+		_, err = parseDotEnv("A=1\nFOO=\"bar")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "line 2: unterminated quoted value")
+
+		_, err = parseDotEnv("FOO='bar' baz")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestDotEnv(t *testing.T) {
+	Convey("Init returns an error without a path", t, func() {
+		de := &DotEnv{}
+		So(de.Init(map[string]string{}), ShouldEqual, ErrNoPath)
+	})
+
+	Convey("DotEnv reads values using environment keys", t, func() {
+		de := &DotEnv{}
+		So(de.Init(map[string]string{"path": "testdata/base.env"}), ShouldBeNil)
+
+		v, err := de.Get("BindAddr", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "localhost:8080")
+
+		v, err = de.Get("LOG_LEVEL", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "info")
+
+		od := "override"
+		v, err = de.Get("Missing", &od)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "override")
+	})
+
+	Convey("Later files override earlier files", t, func() {
+		de := &DotEnv{}
+		So(de.Init(map[string]string{"path": "testdata/base.env, testdata/local.env"}), ShouldBeNil)
+
+		v, err := de.Get("AppName", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "local")
+
+		v, err = de.Get("LogLevel", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "info")
+	})
+
+	Convey("DotEnv supports prefix and infix", t, func() {
+		de := &DotEnv{}
+		So(de.Init(map[string]string{"path": "testdata/local.env", "prefix": "FOO"}), ShouldBeNil)
+
+		v, err := de.Get("BindAddr", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "foo:9090")
+
+		de = &DotEnv{}
+		So(de.Init(map[string]string{"path": "testdata/local.env", "prefix": "FOO", "infix": "__"}), ShouldBeNil)
+
+		v, err = de.Get("BindAddr", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "")
+	})
+
+	Convey("DotEnv reads map values", t, func() {
+		de := &DotEnv{}
+		So(de.Init(map[string]string{"path": "testdata/local.env", "prefix": "FOO"}), ShouldBeNil)
+
+		So(de.Register("LabelsExtra", "", map[string]string{}, reflect.TypeOf("")), ShouldBeNil)
+		m, err := de.GetMap("Labels")
+		So(err, ShouldBeNil)
+		So(m, ShouldResemble, map[string]string{"app": "gofigure", "tier": "web"})
+
+		v, err := de.Get("LabelsExtra", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "z")
+	})
+}
@@ -1,35 +1,58 @@
 package sources
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
 	"reflect"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/ian-kent/envconf"
 )
 
 // Environment implements environment variable configuration using envconf
+//
+// If the fileSuffix param is set, e.g. to _FILE, and a variable isn't
+// set, the value is read from the file named by the variable with the
+// suffix, e.g. DB_PASSWORD_FILE=/run/secrets/db_password
+//
+// If the nested param is true, variables for fields in nested structs
+// are prefixed with the parent field, e.g. ADVANCED_MAX_BYTES
 type Environment struct {
+	logging
+
 	prefix        string
 	infix         string
+	fileSuffix    string
 	fields        map[string]string
 	supportArrays bool
+	nested        bool
 }
 
 var camelRe1 = regexp.MustCompile("(.)([A-Z][a-z]+)")
 var camelRe2 = regexp.MustCompile("([a-z0-9])([A-Z])")
 
+// camelToSnake converts a key to an environment variable name.
+// Each part of a nested key, e.g. Upstreams.0.Host, is
+// converted separately, e.g. UPSTREAMS_0_HOST.
 func camelToSnake(camel string) (snake string) {
-	snake = camelRe1.ReplaceAllString(camel, "${1}_${2}")
-	snake = camelRe2.ReplaceAllString(snake, "${1}_${2}")
-	return strings.ToUpper(snake)
+	parts := strings.Split(camel, KeySeparator)
+	for i, p := range parts {
+		p = camelRe1.ReplaceAllString(p, "${1}_${2}")
+		p = camelRe2.ReplaceAllString(p, "${1}_${2}")
+		parts[i] = strings.ToUpper(p)
+	}
+	return strings.Join(parts, "_")
 }
 
 // Init is called at the start of a new struct
 func (env *Environment) Init(args map[string]string) error {
 	env.infix = "_"
 	env.prefix = ""
+	env.fileSuffix = ""
 	env.fields = make(map[string]string)
 
 	if envPrefix, ok := args["prefix"]; ok {
@@ -38,6 +61,14 @@ func (env *Environment) Init(args map[string]string) error {
 	if envInfix, ok := args["infix"]; ok {
 		env.infix = envInfix
 	}
+	if envFileSuffix, ok := args["fileSuffix"]; ok {
+		env.fileSuffix = envFileSuffix
+	}
+	nested, err := parseNested(args)
+	if err != nil {
+		return err
+	}
+	env.nested = nested
 
 	if v := os.Getenv("GOFIGURE_ENV_ARRAY"); v == "1" || strings.ToLower(v) == "true" || strings.ToLower(v) == "y" {
 		env.supportArrays = true
@@ -46,6 +77,24 @@ func (env *Environment) Init(args map[string]string) error {
 	return nil
 }
 
+// Nested returns true if the nested param is set
+func (env *Environment) Nested() bool {
+	return env.nested
+}
+
+// parseNested returns the value of the nested param
+func parseNested(args map[string]string) (bool, error) {
+	n, ok := args["nested"]
+	if !ok {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(n)
+	if err != nil {
+		return false, fmt.Errorf("Invalid nested param: %s", err)
+	}
+	return b, nil
+}
+
 // Register is called to register each struct field
 func (env *Environment) Register(key, defaultValue string, params map[string]string, t reflect.Type) error {
 	env.fields[camelToSnake(key)] = defaultValue
@@ -54,27 +103,66 @@ func (env *Environment) Register(key, defaultValue string, params map[string]str
 
 // Get is called to retrieve a key value
 func (env *Environment) Get(key string, overrideDefault *string) (string, error) {
+	eK := env.Describe(key)
 	key = camelToSnake(key)
 	def := env.fields[key]
 	if overrideDefault != nil {
 		def = *overrideDefault
 	}
-	eK := key
-	if len(env.prefix) > 0 {
-		eK = env.prefix + env.infix + key
+	if v, ok, err := env.readFile(eK); ok || err != nil {
+		return v, err
 	}
 	val, err := envconf.FromEnv(eK, def)
 	return val.(string), err
 }
 
+// Lookup is called to retrieve a key value, and
+// returns false if the variable isn't set
+func (env *Environment) Lookup(key string) (string, bool, error) {
+	eK := env.Describe(key)
+	if v, ok, err := env.readFile(eK); ok || err != nil {
+		return v, ok, err
+	}
+	v, ok := os.LookupEnv(eK)
+	return v, ok, nil
+}
+
+// readFile returns the contents of the file named by the variable
+// eK with fileSuffix, if fileSuffix is set and eK is empty
+func (env *Environment) readFile(eK string) (string, bool, error) {
+	if len(env.fileSuffix) == 0 || len(os.Getenv(eK)) > 0 {
+		return "", false, nil
+	}
+	file := os.Getenv(eK + env.fileSuffix)
+	if len(file) == 0 {
+		return "", false, nil
+	}
+	env.printf("Reading '%s' from file '%s'", eK, file)
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", false, fmt.Errorf("Failed to read %s%s: %s", eK, env.fileSuffix, err)
+	}
+	return strings.TrimRight(string(b), "\r\n"), true, nil
+}
+
+// Describe returns the environment variable name for a key
+func (env *Environment) Describe(key string) string {
+	key = camelToSnake(key)
+	if len(env.prefix) > 0 {
+		return env.prefix + env.infix + key
+	}
+	return key
+}
+
 // GetArray is called to retrieve an array value
 func (env *Environment) GetArray(key string, overrideDefault *[]string) ([]string, error) {
 	var oD *string
 	if overrideDefault != nil {
+		ovr := ""
 		if len(*overrideDefault) > 0 {
-			ovr := (*overrideDefault)[0]
-			oD = &ovr
+			ovr = (*overrideDefault)[0]
 		}
+		oD = &ovr
 	}
 	v, e := env.Get(key, oD)
 	arr := []string{v}
@@ -91,6 +179,91 @@ func (env *Environment) GetArray(key string, overrideDefault *[]string) ([]strin
 	return []string{}, e
 }
 
+// GetMap is called to retrieve a map value, either from a comma
+// separated list, e.g. LABELS=k1=v1,k2=v2, or from variables with
+// the key as a prefix, e.g. LABELS_K1=v1, which have lowercased keys.
+// Variables for other fields, e.g. LABELS_EXTRA, aren't map keys.
+func (env *Environment) GetMap(key string) (map[string]string, error) {
+	eK := env.Describe(key)
+	vars := make(map[string]string)
+	for _, e := range os.Environ() {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) == 2 {
+			vars[kv[0]] = kv[1]
+		}
+	}
+	exclude := make(map[string]bool)
+	if len(env.fileSuffix) > 0 {
+		exclude[eK+env.fileSuffix] = true
+	}
+	for k := range env.fields {
+		n := env.Describe(k)
+		if n != eK {
+			exclude[n] = true
+		}
+		if len(env.fileSuffix) > 0 {
+			exclude[n+env.fileSuffix] = true
+		}
+	}
+	return mapFromVars(vars, eK, exclude)
+}
+
+// Keys is called to retrieve the keys of a map or slice of structs,
+// using variables with the key as a prefix, e.g. UPSTREAMS_0_HOST
+func (env *Environment) Keys(key string) ([]string, error) {
+	var names []string
+	for _, e := range os.Environ() {
+		names = append(names, strings.SplitN(e, "=", 2)[0])
+	}
+	return keysFromVars(names, env.Describe(key)), nil
+}
+
+// keysFromVars returns the lowercased part of each variable name
+// following the prefix eK, up to the next underscore
+func keysFromVars(names []string, eK string) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, n := range names {
+		if !strings.HasPrefix(n, eK+"_") {
+			continue
+		}
+		rest := n[len(eK)+1:]
+		i := strings.Index(rest, "_")
+		if i < 1 {
+			continue
+		}
+		k := strings.ToLower(rest[:i])
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// mapFromVars returns the map value for the variable name eK,
+// ignoring excluded variables, and is used by both Environment and DotEnv
+func mapFromVars(vars map[string]string, eK string, exclude map[string]bool) (map[string]string, error) {
+	m := make(map[string]string)
+	if v := vars[eK]; len(v) > 0 {
+		var err error
+		m, err = ParseMap(strings.Split(v, ","))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", eK, err)
+		}
+	}
+	for k, v := range vars {
+		if exclude[k] {
+			continue
+		}
+		if strings.HasPrefix(k, eK+"_") && len(k) > len(eK)+1 && len(v) > 0 {
+			m[strings.ToLower(k[len(eK)+1:])] = v
+		}
+	}
+	return m, nil
+}
+
 // Cleanup is called at the end of parsing
 func (env *Environment) Cleanup() {
 
@@ -1,6 +1,8 @@
 package sources
 
 import (
+	"os"
+	"reflect"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -11,5 +13,190 @@ func TestCamelToSnake(t *testing.T) {
 		So(camelToSnake("CamelCase"), ShouldEqual, "CAMEL_CASE")
 		So(camelToSnake("camelCase"), ShouldEqual, "CAMEL_CASE")
 		So(camelToSnake("CaMeLCase"), ShouldEqual, "CA_ME_L_CASE")
+		So(camelToSnake("Upstreams.0.MaxConns"), ShouldEqual, "UPSTREAMS_0_MAX_CONNS")
+	})
+}
+
+func TestEnvironmentFileSuffix(t *testing.T) {
+	Convey("Environment reads values from files when fileSuffix is set", t, func() {
+		os.Clearenv()
+		os.Setenv("FOO_DB_PASSWORD_FILE", "testdata/secrets/DB_PASSWORD")
+		env := &Environment{}
+		So(env.Init(map[string]string{"prefix": "FOO", "fileSuffix": "_FILE"}), ShouldBeNil)
+
+		v, err := env.Get("DbPassword", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "hunter2")
+
+		a, err := env.GetArray("DbPassword", nil)
+		So(err, ShouldBeNil)
+		So(a, ShouldResemble, []string{"hunter2"})
+	})
+
+	Convey("Variables take precedence over files", t, func() {
+		os.Clearenv()
+		os.Setenv("DB_PASSWORD", "direct")
+		os.Setenv("DB_PASSWORD_FILE", "testdata/secrets/DB_PASSWORD")
+		env := &Environment{}
+		So(env.Init(map[string]string{"fileSuffix": "_FILE"}), ShouldBeNil)
+
+		v, err := env.Get("DbPassword", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "direct")
+	})
+
+	Convey("Files are ignored unless fileSuffix is set", t, func() {
+		os.Clearenv()
+		os.Setenv("DB_PASSWORD_FILE", "testdata/secrets/DB_PASSWORD")
+		env := &Environment{}
+		So(env.Init(map[string]string{}), ShouldBeNil)
+
+		v, err := env.Get("DbPassword", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "")
+	})
+
+	Convey("Unreadable files return an error", t, func() {
+		os.Clearenv()
+		os.Setenv("DB_PASSWORD_FILE", "testdata/secrets/missing")
+		env := &Environment{}
+		So(env.Init(map[string]string{"fileSuffix": "_FILE"}), ShouldBeNil)
+
+		_, err := env.Get("DbPassword", nil)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldStartWith, "Failed to read DB_PASSWORD_FILE: ")
+	})
+
+	os.Clearenv()
+}
+
+func TestEnvironmentLookup(t *testing.T) {
+	Convey("Lookup returns false if the variable isn't set", t, func() {
+		os.Clearenv()
+		env := &Environment{}
+		So(env.Init(map[string]string{"fileSuffix": "_FILE"}), ShouldBeNil)
+		So(env.Register("DbPassword", "default", map[string]string{}, reflect.TypeOf("")), ShouldBeNil)
+
+		_, ok, err := env.Lookup("DbPassword")
+		So(err, ShouldBeNil)
+		So(ok, ShouldBeFalse)
+
+		os.Setenv("DB_PASSWORD", "")
+		v, ok, err := env.Lookup("DbPassword")
+		So(err, ShouldBeNil)
+		So(ok, ShouldBeTrue)
+		So(v, ShouldEqual, "")
+
+		os.Setenv("DB_PASSWORD_FILE", "testdata/secrets/DB_PASSWORD")
+		v, ok, err = env.Lookup("DbPassword")
+		So(err, ShouldBeNil)
+		So(ok, ShouldBeTrue)
+		So(v, ShouldEqual, "hunter2")
+	})
+}
+
+func TestEnvironmentGetMap(t *testing.T) {
+	Convey("GetMap reads comma separated key=value pairs", t, func() {
+		os.Clearenv()
+		os.Setenv("LABELS", "app=gofigure,tier=web")
+		env := &Environment{}
+		So(env.Init(map[string]string{}), ShouldBeNil)
+
+		m, err := env.GetMap("Labels")
+		So(err, ShouldBeNil)
+		So(m, ShouldResemble, map[string]string{"app": "gofigure", "tier": "web"})
+	})
+
+	Convey("GetMap reads variables with the key as a prefix", t, func() {
+		os.Clearenv()
+		os.Setenv("FOO_LABELS", "app=gofigure,tier=web")
+		os.Setenv("FOO_LABELS_TIER", "db")
+		os.Setenv("FOO_LABELS_OWNER", "ops")
+		os.Setenv("FOO_LABELS_FILE", "testdata/labels")
+		env := &Environment{}
+		So(env.Init(map[string]string{"prefix": "FOO", "fileSuffix": "_FILE"}), ShouldBeNil)
+
+		m, err := env.GetMap("Labels")
+		So(err, ShouldBeNil)
+		So(m, ShouldResemble, map[string]string{"app": "gofigure", "tier": "db", "owner": "ops"})
+	})
+
+	Convey("GetMap ignores variables for other fields", t, func() {
+		os.Clearenv()
+		os.Setenv("LABELS_TIER", "db")
+		os.Setenv("LABELS_EXTRA", "z")
+		os.Setenv("LABELS_EXTRA_FILE", "testdata/labels")
+		env := &Environment{}
+		So(env.Init(map[string]string{"fileSuffix": "_FILE"}), ShouldBeNil)
+		So(env.Register("Labels", "", map[string]string{}, reflect.TypeOf(map[string]string{})), ShouldBeNil)
+		So(env.Register("LabelsExtra", "", map[string]string{}, reflect.TypeOf("")), ShouldBeNil)
+
+		m, err := env.GetMap("Labels")
+		So(err, ShouldBeNil)
+		So(m, ShouldResemble, map[string]string{"tier": "db"})
+	})
+
+	Convey("GetMap returns an empty map if no variables are set", t, func() {
+		os.Clearenv()
+		env := &Environment{}
+		So(env.Init(map[string]string{}), ShouldBeNil)
+
+		m, err := env.GetMap("Labels")
+		So(err, ShouldBeNil)
+		So(m, ShouldResemble, map[string]string{})
+	})
+
+	Convey("GetMap returns an error for invalid values", t, func() {
+		os.Clearenv()
+		os.Setenv("LABELS", "app")
+		env := &Environment{}
+		So(env.Init(map[string]string{}), ShouldBeNil)
+
+		_, err := env.GetMap("Labels")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "LABELS: Invalid map value 'app', expected key=value")
+	})
+
+	os.Clearenv()
+}
+
+func TestEnvironmentKeys(t *testing.T) {
+	Convey("Keys returns the keys of variables with the key as a prefix", t, func() {
+		os.Clearenv()
+		os.Setenv("FOO_UPSTREAMS_1_HOST", "b")
+		os.Setenv("FOO_UPSTREAMS_0_HOST", "a")
+		os.Setenv("FOO_UPSTREAMS_0_PORT", "80")
+		os.Setenv("FOO_UPSTREAMS", "ignored")
+		os.Setenv("UPSTREAMS_2_HOST", "ignored")
+		env := &Environment{}
+		So(env.Init(map[string]string{"prefix": "FOO"}), ShouldBeNil)
+
+		k, err := env.Keys("Upstreams")
+		So(err, ShouldBeNil)
+		So(k, ShouldResemble, []string{"0", "1"})
+
+		v, err := env.Get("Upstreams.1.Host", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "b")
+
+		k, err = env.Keys("Missing")
+		So(err, ShouldBeNil)
+		So(k, ShouldBeNil)
+	})
+
+	os.Clearenv()
+}
+
+func TestEnvironmentNested(t *testing.T) {
+	Convey("Nested returns the nested param", t, func() {
+		env := &Environment{}
+		So(env.Init(map[string]string{}), ShouldBeNil)
+		So(env.Nested(), ShouldBeFalse)
+
+		So(env.Init(map[string]string{"nested": "true", "prefix": "FOO"}), ShouldBeNil)
+		So(env.Nested(), ShouldBeTrue)
+		So(env.Describe("Advanced.MaxBytes"), ShouldEqual, "FOO_ADVANCED_MAX_BYTES")
+
+		So(env.Init(map[string]string{"nested": "maybe"}), ShouldNotBeNil)
 	})
 }
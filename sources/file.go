@@ -0,0 +1,45 @@
+package sources
+
+import (
+	"fmt"
+	"io/ioutil"
+)
+
+// File implements configuration using a structured file, decoded
+// using the Decoder registered for the format param or the file extension
+type File struct {
+	structured
+}
+
+// Init is called at the start of a new struct
+func (f *File) Init(args map[string]string) error {
+	return f.initFile(args, args["format"])
+}
+
+// initFile reads the file given by the path param and
+// decodes it using the decoder for format
+func (s *structured) initFile(args map[string]string, format string) error {
+	path, ok := args["path"]
+	if !ok || len(path) == 0 {
+		return ErrNoPath
+	}
+
+	d, err := DecoderFor(format, path, "")
+	if err != nil {
+		return err
+	}
+
+	s.printf("Reading file '%s'", path)
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	data, err := d.Decode(b)
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+
+	s.init(data)
+	return nil
+}
@@ -0,0 +1,38 @@
+package sources
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFile(t *testing.T) {
+	Convey("Init returns an error without a path", t, func() {
+		f := &File{}
+		So(f.Init(map[string]string{}), ShouldEqual, ErrNoPath)
+	})
+
+	Convey("Init returns an error for unknown formats", t, func() {
+		f := &File{}
+		So(f.Init(map[string]string{"path": "testdata/base.env"}), ShouldEqual, ErrUnknownFormat)
+		So(f.Init(map[string]string{"path": "testdata/config.json", "format": "xml"}), ShouldEqual, ErrUnknownFormat)
+	})
+
+	Convey("File chooses the decoder using the file extension", t, func() {
+		for _, p := range []string{"json", "yaml", "toml", "ini", "properties"} {
+			f := &File{}
+			So(f.Init(map[string]string{"path": "testdata/config." + p}), ShouldBeNil)
+			v, err := f.Get("name", nil)
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, "gofigure")
+		}
+	})
+
+	Convey("File chooses the decoder using the format param", t, func() {
+		f := &File{}
+		So(f.Init(map[string]string{"path": "testdata/config.json", "format": "yaml"}), ShouldBeNil)
+		v, err := f.Get("name", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "gofigure")
+	})
+}
@@ -0,0 +1,272 @@
+package sources
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTP implements configuration using a document fetched from a URL
+//
+// The following params are supported:
+//   - url, the URL to fetch (required)
+//   - format, a registered decoder format, e.g. json or yaml (default based on Content-Type)
+//   - timeout, the request timeout (default 10s)
+//   - retries, the number of retries for failed requests (default 2)
+//   - backoff, the delay before the first retry, doubled for each retry (default 500ms)
+//   - cache, a path to store the last known good document, used if the URL can't be fetched.
+//     The Content-Type of the document is stored in the same path with a .content-type suffix.
+//
+// Responses are cached between calls to Init according to the Cache-Control
+// header, and revalidated using ETag and If-None-Match.
+type HTTP struct {
+	structured
+
+	// Client is used to make requests. If nil, a client is
+	// created using the timeout param.
+	Client *http.Client
+	// Decoder is used to decode the document. If nil, the decoder
+	// is chosen using the format param or the Content-Type header.
+	Decoder Decoder
+
+	url         string
+	etag        string
+	body        []byte
+	contentType string
+	expires     time.Time
+}
+
+type httpStatusError struct {
+	url    string
+	status int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("Unexpected status code %d from %s", e.status, e.url)
+}
+
+// Init is called at the start of a new struct
+func (h *HTTP) Init(args map[string]string) error {
+	url, ok := args["url"]
+	if !ok || len(url) == 0 {
+		return ErrNoURL
+	}
+
+	timeout := 10 * time.Second
+	if v, ok := args["timeout"]; ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		timeout = d
+	}
+	retries := 2
+	if v, ok := args["retries"]; ok {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		retries = i
+	}
+	backoff := 500 * time.Millisecond
+	if v, ok := args["backoff"]; ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		backoff = d
+	}
+
+	if url != h.url {
+		h.url = url
+		h.etag = ""
+		h.body = nil
+	}
+
+	var body []byte
+	var contentType string
+	if h.body != nil && time.Now().Before(h.expires) {
+		h.printf("Using cached response for '%s'", url)
+		body, contentType = h.body, h.contentType
+	} else {
+		client := h.Client
+		if client == nil {
+			client = &http.Client{Timeout: timeout}
+		}
+
+		var err error
+		body, contentType, err = h.fetch(client, retries, backoff)
+		if err != nil {
+			cache, ok := args["cache"]
+			if !ok || len(cache) == 0 {
+				return err
+			}
+			h.printf("Failed to fetch '%s', using last known good copy '%s': %s", url, cache, err)
+			body, contentType, err = readCache(cache)
+			if err != nil {
+				return err
+			}
+		} else if cache, ok := args["cache"]; ok && len(cache) > 0 && h.body != nil {
+			// h.body is nil if the response was sent with no-store
+			err = writeCache(cache, body, contentType)
+			if err != nil {
+				h.printf("Failed to write last known good copy '%s': %s", cache, err)
+			}
+		}
+	}
+
+	d, err := h.decoder(args["format"], contentType)
+	if err != nil {
+		return err
+	}
+	data, err := d.Decode(body)
+	if err != nil {
+		return err
+	}
+
+	h.init(data)
+	return nil
+}
+
+// fetch requests the URL, retrying network errors and 5xx responses
+func (h *HTTP) fetch(client *http.Client, retries int, backoff time.Duration) ([]byte, string, error) {
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			h.printf("Retrying '%s' in %s", h.url, backoff)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		var body []byte
+		var contentType string
+		body, contentType, err = h.request(client)
+		if err == nil {
+			return body, contentType, nil
+		}
+		h.printf("Request for '%s' failed: %s", h.url, err)
+		if se, ok := err.(*httpStatusError); ok && se.status < 500 {
+			break
+		}
+	}
+	return nil, "", err
+}
+
+func (h *HTTP) request(client *http.Client) ([]byte, string, error) {
+	req, err := http.NewRequest("GET", h.url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if h.body != nil && len(h.etag) > 0 {
+		req.Header.Set("If-None-Match", h.etag)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotModified && h.body != nil {
+		h.printf("Response for '%s' not modified", h.url)
+		body, contentType := h.body, h.contentType
+		h.cacheResponse(res, body, contentType)
+		return body, contentType, nil
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, "", &httpStatusError{h.url, res.StatusCode}
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	contentType := res.Header.Get("Content-Type")
+	h.cacheResponse(res, body, contentType)
+	return body, contentType, nil
+}
+
+// cacheResponse stores the response according to its Cache-Control header
+func (h *HTTP) cacheResponse(res *http.Response, body []byte, contentType string) {
+	var maxAge time.Duration
+	for _, directive := range strings.Split(res.Header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store":
+			h.etag = ""
+			h.body = nil
+			return
+		case directive == "no-cache":
+			maxAge = -1
+		case strings.HasPrefix(directive, "max-age=") && maxAge >= 0:
+			if s, err := strconv.Atoi(directive[len("max-age="):]); err == nil {
+				maxAge = time.Duration(s) * time.Second
+			}
+		}
+	}
+
+	h.expires = time.Time{}
+	if maxAge > 0 {
+		h.expires = time.Now().Add(maxAge)
+	}
+	h.body = body
+	h.contentType = contentType
+	if etag := res.Header.Get("ETag"); len(etag) > 0 {
+		h.etag = etag
+	}
+}
+
+// decoder returns the Decoder for the response, defaulting
+// to JSON if the format can't be determined
+func (h *HTTP) decoder(format, contentType string) (Decoder, error) {
+	if h.Decoder != nil {
+		return h.Decoder, nil
+	}
+	d, err := DecoderFor(format, h.url, contentType)
+	if err == ErrUnknownFormat && len(format) == 0 {
+		return DecoderFor("json", "", "")
+	}
+	return d, err
+}
+
+// contentTypeSuffix is appended to the cache path to store the
+// Content-Type of the last known good copy
+const contentTypeSuffix = ".content-type"
+
+// readCache reads the last known good copy and its Content-Type,
+// which is empty if it wasn't stored
+func readCache(path string) ([]byte, string, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	contentType, err := ioutil.ReadFile(path + contentTypeSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, "", err
+	}
+	return body, string(contentType), nil
+}
+
+// writeCache writes the last known good copy and its Content-Type
+func writeCache(path string, body []byte, contentType string) error {
+	err := writeFile(path+contentTypeSuffix, []byte(contentType))
+	if err != nil {
+		return err
+	}
+	return writeFile(path, body)
+}
+
+// writeFile writes to a temporary file and renames it, so
+// an interrupted write can't leave a partial document
+func writeFile(path string, b []byte) error {
+	tmp := path + ".tmp"
+	err := ioutil.WriteFile(tmp, b, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,178 @@
+package sources
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHTTP(t *testing.T) {
+	Convey("Init returns an error without a URL", t, func() {
+		h := &HTTP{}
+		So(h.Init(map[string]string{}), ShouldEqual, ErrNoURL)
+	})
+
+	Convey("HTTP reads values from a JSON document", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"name":"gofigure","advanced":{"hosts":["a","b"]}}`))
+		}))
+		defer ts.Close()
+
+		h := &HTTP{}
+		So(h.Init(map[string]string{"url": ts.URL}), ShouldBeNil)
+
+		v, err := h.Get("name", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "gofigure")
+
+		a, err := h.GetArray("advanced.hosts", nil)
+		So(err, ShouldBeNil)
+		So(a, ShouldResemble, []string{"a", "b"})
+	})
+
+	Convey("HTTP chooses a decoder using the Content-Type or format", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/x-yaml; charset=utf-8")
+			w.Write([]byte("name: gofigure\n"))
+		}))
+		defer ts.Close()
+
+		h := &HTTP{}
+		So(h.Init(map[string]string{"url": ts.URL}), ShouldBeNil)
+		v, err := h.Get("name", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "gofigure")
+
+		h = &HTTP{}
+		So(h.Init(map[string]string{"url": ts.URL, "format": "json"}), ShouldNotBeNil)
+	})
+
+	Convey("HTTP revalidates cached responses using ETags", t, func() {
+		requests := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			w.Write([]byte(`{"name":"gofigure"}`))
+		}))
+		defer ts.Close()
+
+		h := &HTTP{}
+		So(h.Init(map[string]string{"url": ts.URL}), ShouldBeNil)
+		So(h.Init(map[string]string{"url": ts.URL}), ShouldBeNil)
+		So(requests, ShouldEqual, 2)
+		v, err := h.Get("name", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "gofigure")
+	})
+
+	Convey("HTTP honours Cache-Control max-age", t, func() {
+		requests := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Write([]byte(`{"name":"gofigure"}`))
+		}))
+		defer ts.Close()
+
+		h := &HTTP{}
+		So(h.Init(map[string]string{"url": ts.URL}), ShouldBeNil)
+		So(h.Init(map[string]string{"url": ts.URL}), ShouldBeNil)
+		So(requests, ShouldEqual, 1)
+	})
+
+	Convey("HTTP retries server errors", t, func() {
+		requests := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if requests < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(`{"name":"gofigure"}`))
+		}))
+		defer ts.Close()
+
+		h := &HTTP{}
+		So(h.Init(map[string]string{"url": ts.URL, "backoff": "1ms"}), ShouldBeNil)
+		So(requests, ShouldEqual, 3)
+
+		requests = 0
+		h = &HTTP{}
+		err := h.Init(map[string]string{"url": ts.URL, "backoff": "1ms", "retries": "1"})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "Unexpected status code 503 from "+ts.URL)
+		So(requests, ShouldEqual, 2)
+	})
+
+	Convey("HTTP doesn't retry client errors", t, func() {
+		requests := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer ts.Close()
+
+		h := &HTTP{}
+		So(h.Init(map[string]string{"url": ts.URL, "backoff": "1ms"}), ShouldNotBeNil)
+		So(requests, ShouldEqual, 1)
+	})
+
+	Convey("HTTP falls back to the last known good copy", t, func() {
+		dir, err := ioutil.TempDir("", "gofigure")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		cache := filepath.Join(dir, "config.json")
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"name":"gofigure"}`))
+		}))
+
+		h := &HTTP{}
+		So(h.Init(map[string]string{"url": ts.URL, "cache": cache}), ShouldBeNil)
+		ts.Close()
+
+		h = &HTTP{}
+		So(h.Init(map[string]string{"url": ts.URL, "cache": cache, "retries": "0"}), ShouldBeNil)
+		v, err := h.Get("name", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "gofigure")
+
+		h = &HTTP{}
+		So(h.Init(map[string]string{"url": ts.URL, "retries": "0"}), ShouldNotBeNil)
+	})
+
+	Convey("HTTP uses the Content-Type of the last known good copy", t, func() {
+		dir, err := ioutil.TempDir("", "gofigure")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		cache := filepath.Join(dir, "config")
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/x-yaml")
+			w.Write([]byte("name: gofigure\nadvanced:\n  hosts: [a, b]\n"))
+		}))
+
+		h := &HTTP{}
+		So(h.Init(map[string]string{"url": ts.URL, "cache": cache}), ShouldBeNil)
+		ts.Close()
+
+		h = &HTTP{}
+		So(h.Init(map[string]string{"url": ts.URL, "cache": cache, "retries": "0"}), ShouldBeNil)
+		v, err := h.Get("name", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "gofigure")
+		a, err := h.GetArray("advanced.hosts", nil)
+		So(err, ShouldBeNil)
+		So(a, ShouldResemble, []string{"a", "b"})
+	})
+}
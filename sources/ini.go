@@ -0,0 +1,73 @@
+package sources
+
+import (
+	"fmt"
+	"strings"
+)
+
+// INIFile implements configuration using an INI file
+//
+// Sections map to nested structs, with dotted section names, e.g.
+// [advanced.limits], used for deeper nesting. Keys which are repeated
+// within a section are returned as arrays.
+type INIFile struct {
+	structured
+}
+
+// Init is called at the start of a new struct
+func (ini *INIFile) Init(args map[string]string) error {
+	return ini.initFile(args, "ini")
+}
+
+func decodeINI(b []byte) (map[string]interface{}, error) {
+	return parseINI(string(b))
+}
+
+func parseINI(content string) (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+	var section []string
+
+	lines := strings.Split(strings.Replace(content, "\r\n", "\n", -1), "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' {
+			if line[len(line)-1] != ']' {
+				return nil, fmt.Errorf("line %d: invalid section", i+1)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if len(name) == 0 {
+				return nil, fmt.Errorf("line %d: invalid section", i+1)
+			}
+			section = strings.Split(name, KeySeparator)
+			for j := range section {
+				section[j] = strings.TrimSpace(section[j])
+			}
+			continue
+		}
+
+		sep := strings.IndexAny(line, "=:")
+		if sep < 1 {
+			return nil, fmt.Errorf("line %d: expected key=value", i+1)
+		}
+		key := strings.TrimSpace(line[:sep])
+		val := strings.TrimSpace(line[sep+1:])
+
+		if len(val) > 1 && (val[0] == '"' || val[0] == '\'') && val[len(val)-1] == val[0] {
+			val = val[1 : len(val)-1]
+		} else if idx := strings.IndexAny(val, ";#"); idx > 0 && (val[idx-1] == ' ' || val[idx-1] == '\t') {
+			val = strings.TrimSpace(val[:idx])
+		}
+
+		parts := append(append([]string{}, section...), key)
+		err := setPath(data, parts, val)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", i+1, err)
+		}
+	}
+
+	return data, nil
+}
@@ -0,0 +1,68 @@
+package sources
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseINI(t *testing.T) {
+	Convey("parseINI returns errors for invalid input", t, func() {
+		_, err := parseINI("[section")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "line 1: invalid section")
+
+		_, err = parseINI("a=1\nfoo")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "line 2: expected key=value")
+
+		_, err = parseINI("a=1\n[a]\nb=2")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "line 3: key 'a' is not a map")
+	})
+}
+
+func TestINIFile(t *testing.T) {
+	Convey("Init returns an error without a path", t, func() {
+		ini := &INIFile{}
+		So(ini.Init(map[string]string{}), ShouldEqual, ErrNoPath)
+	})
+
+	Convey("INIFile reads values from an INI file", t, func() {
+		ini := &INIFile{}
+		So(ini.Init(map[string]string{"path": "testdata/config.ini"}), ShouldBeNil)
+
+		v, err := ini.Get("name", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "gofigure")
+
+		v, err = ini.Get("port", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "8080")
+
+		v, err = ini.Get("advanced.max_errors", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "10")
+
+		v, err = ini.Get("advanced.quoted", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "a ; b")
+
+		v, err = ini.Get("advanced.deeper.level", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "3")
+	})
+
+	Convey("INIFile returns repeated keys as arrays", t, func() {
+		ini := &INIFile{}
+		So(ini.Init(map[string]string{"path": "testdata/config.ini"}), ShouldBeNil)
+
+		v, err := ini.GetArray("advanced.hosts", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldResemble, []string{"alpha", "beta"})
+
+		v, err = ini.GetArray("name", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldResemble, []string{"gofigure"})
+	})
+}
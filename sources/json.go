@@ -0,0 +1,26 @@
+package sources
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// JSONFile implements configuration using a JSON file
+type JSONFile struct {
+	structured
+}
+
+// Init is called at the start of a new struct
+func (j *JSONFile) Init(args map[string]string) error {
+	return j.initFile(args, "json")
+}
+
+// decodeJSON decodes numbers as json.Number, so large integers
+// aren't rounded by conversion to float64
+func decodeJSON(b []byte) (map[string]interface{}, error) {
+	var data map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	err := d.Decode(&data)
+	return data, err
+}
@@ -0,0 +1,144 @@
+package sources
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestJSONFile(t *testing.T) {
+	Convey("Init returns an error without a path", t, func() {
+		j := &JSONFile{}
+		So(j.Init(map[string]string{}), ShouldEqual, ErrNoPath)
+	})
+
+	Convey("Init returns an error if the file doesn't exist", t, func() {
+		j := &JSONFile{}
+		So(j.Init(map[string]string{"path": "testdata/missing.json"}), ShouldNotBeNil)
+	})
+
+	Convey("JSONFile reads values from a JSON file", t, func() {
+		j := &JSONFile{}
+		So(j.Init(map[string]string{"path": "testdata/config.json"}), ShouldBeNil)
+
+		v, err := j.Get("name", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "gofigure")
+
+		v, err = j.Get("port", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "8080")
+
+		v, err = j.Get("ratio", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "0.5")
+
+		v, err = j.Get("id", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "9007199254740993")
+
+		v, err = j.Get("enabled", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "true")
+
+		v, err = j.Get("maxBytes", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "1024")
+
+		v, err = j.Get("advanced.max_errors", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "10")
+
+		_, err = j.Get("advanced", nil)
+		So(err, ShouldEqual, ErrUnsupportedValue)
+	})
+
+	Convey("Case-insensitive matches should be deterministic", t, func() {
+		b := []byte(`{"PORT": 1, "Port": 2, "pORT": 3, "p": {"Host": "a", "HOST": "b"}}`)
+		for i := 0; i < 20; i++ {
+			data, err := decodeJSON(b)
+			So(err, ShouldBeNil)
+			j := &JSONFile{}
+			j.init(data)
+
+			v, err := j.Get("port", nil)
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, "1")
+
+			v, err = j.Get("p.host", nil)
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, "b")
+		}
+	})
+
+	Convey("JSONFile returns defaults for missing keys", t, func() {
+		j := &JSONFile{}
+		So(j.Init(map[string]string{"path": "testdata/config.json"}), ShouldBeNil)
+		So(j.Register("missing", "default", nil, nil), ShouldBeNil)
+
+		v, err := j.Get("missing", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "default")
+
+		od := "override"
+		v, err = j.Get("missing", &od)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "override")
+
+		v, err = j.Get("empty", &od)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "override")
+	})
+
+	Convey("JSONFile reads arrays from a JSON file", t, func() {
+		j := &JSONFile{}
+		So(j.Init(map[string]string{"path": "testdata/config.json"}), ShouldBeNil)
+
+		v, err := j.GetArray("tags", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldResemble, []string{"a", "b", "c"})
+
+		v, err = j.GetArray("numbers", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldResemble, []string{"1", "2", "3"})
+
+		v, err = j.GetArray("advanced.hosts", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldResemble, []string{"localhost"})
+
+		v, err = j.GetArray("missing", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldResemble, []string{})
+	})
+
+	Convey("JSONFile reads maps from a JSON file", t, func() {
+		j := &JSONFile{}
+		So(j.Init(map[string]string{"path": "testdata/config.json"}), ShouldBeNil)
+
+		m, err := j.GetMap("labels")
+		So(err, ShouldBeNil)
+		So(m, ShouldResemble, map[string]string{"app": "gofigure", "tier": "web"})
+
+		m, err = j.GetMap("missing")
+		So(err, ShouldBeNil)
+		So(m, ShouldResemble, map[string]string{})
+
+		_, err = j.GetMap("upstreams")
+		So(err, ShouldEqual, ErrUnsupportedValue)
+
+		k, err := j.Keys("upstreams")
+		So(err, ShouldBeNil)
+		So(k, ShouldResemble, []string{"primary", "secondary"})
+
+		k, err = j.Keys("missing")
+		So(err, ShouldBeNil)
+		So(k, ShouldBeNil)
+
+		k, err = j.Keys("tags")
+		So(err, ShouldBeNil)
+		So(k, ShouldResemble, []string{"0", "1", "2"})
+
+		_, err = j.Keys("name")
+		So(err, ShouldEqual, ErrUnsupportedValue)
+	})
+}
@@ -0,0 +1,128 @@
+package sources
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PropertiesFile implements configuration using a Java style
+// .properties file
+//
+// Dotted keys, e.g. advanced.max_bytes, map to nested structs, and
+// indexed keys, e.g. hosts.0 and hosts.1, are returned as arrays.
+type PropertiesFile struct {
+	structured
+}
+
+// Init is called at the start of a new struct
+func (p *PropertiesFile) Init(args map[string]string) error {
+	return p.initFile(args, "properties")
+}
+
+func decodeProperties(b []byte) (map[string]interface{}, error) {
+	return parseProperties(string(b))
+}
+
+func parseProperties(content string) (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+
+	lines := strings.Split(strings.Replace(content, "\r\n", "\n", -1), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimLeft(lines[i], " \t\f")
+		if len(line) == 0 || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+
+		// join continuation lines, i.e. those ending in an odd
+		// number of backslashes
+		for trailingBackslashes(line)%2 == 1 {
+			line = line[:len(line)-1]
+			i++
+			if i >= len(lines) {
+				break
+			}
+			line += strings.TrimLeft(lines[i], " \t\f")
+		}
+
+		// the key ends at the first unescaped '=', ':' or whitespace
+		end := len(line)
+		for j := 0; j < len(line); j++ {
+			if line[j] == '\\' {
+				j++
+				continue
+			}
+			if strings.IndexByte("=: \t\f", line[j]) > -1 {
+				end = j
+				break
+			}
+		}
+		key, err := unescapeProperty(line[:end])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNo, err)
+		}
+
+		val := strings.TrimLeft(line[end:], " \t\f")
+		if len(val) > 0 && (val[0] == '=' || val[0] == ':') {
+			val = strings.TrimLeft(val[1:], " \t\f")
+		}
+		val, err = unescapeProperty(val)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNo, err)
+		}
+
+		err = setPath(data, strings.Split(key, KeySeparator), val)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNo, err)
+		}
+	}
+
+	for k, v := range data {
+		data[k] = indexedArrays(v)
+	}
+	return data, nil
+}
+
+func trailingBackslashes(s string) int {
+	n := 0
+	for i := len(s) - 1; i >= 0 && s[i] == '\\'; i-- {
+		n++
+	}
+	return n
+}
+
+func unescapeProperty(s string) (string, error) {
+	var out []rune
+	r := []rune(s)
+	for i := 0; i < len(r); i++ {
+		if r[i] != '\\' || i+1 >= len(r) {
+			out = append(out, r[i])
+			continue
+		}
+		i++
+		switch r[i] {
+		case 't':
+			out = append(out, '\t')
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 'f':
+			out = append(out, '\f')
+		case 'u':
+			if i+4 >= len(r) {
+				return "", fmt.Errorf("invalid unicode escape")
+			}
+			c, err := strconv.ParseUint(string(r[i+1:i+5]), 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("invalid unicode escape")
+			}
+			out = append(out, rune(c))
+			i += 4
+		default:
+			out = append(out, r[i])
+		}
+	}
+	return string(out), nil
+}
@@ -0,0 +1,68 @@
+package sources
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseProperties(t *testing.T) {
+	Convey("parseProperties returns errors for invalid input", t, func() {
+		_, err := parseProperties("a=\\u00")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "line 1: invalid unicode escape")
+
+		_, err = parseProperties("a=1\na.b=2")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "line 2: key 'a' is not a map")
+	})
+}
+
+func TestPropertiesFile(t *testing.T) {
+	Convey("Init returns an error without a path", t, func() {
+		p := &PropertiesFile{}
+		So(p.Init(map[string]string{}), ShouldEqual, ErrNoPath)
+	})
+
+	Convey("PropertiesFile reads values from a properties file", t, func() {
+		p := &PropertiesFile{}
+		So(p.Init(map[string]string{"path": "testdata/config.properties"}), ShouldBeNil)
+
+		v, err := p.Get("name", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "gofigure")
+
+		v, err = p.Get("port", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "8080")
+
+		v, err = p.Get("greeting", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "Hello\tworld")
+
+		v, err = p.Get("long", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "first second")
+
+		v, err = p.Get("key=with=equals", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "é")
+
+		v, err = p.Get("advanced.max_errors", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "10")
+
+		v, err = p.Get("advanced.deeper.level", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "3")
+	})
+
+	Convey("PropertiesFile returns indexed keys as arrays", t, func() {
+		p := &PropertiesFile{}
+		So(p.Init(map[string]string{"path": "testdata/config.properties"}), ShouldBeNil)
+
+		v, err := p.GetArray("advanced.hosts", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldResemble, []string{"alpha", "beta"})
+	})
+}
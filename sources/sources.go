@@ -2,8 +2,10 @@ package sources
 
 import (
 	"errors"
+	"fmt"
 	"log"
 	"reflect"
+	"strings"
 )
 
 // Logger is called for each log message. If nil,
@@ -24,13 +26,48 @@ func printf(message string, args ...interface{}) {
 	}
 }
 
+// logging is embedded by sources to log using the logger set by SetLogger
+type logging struct {
+	logger func(message string, args ...interface{})
+}
+
+// SetLogger sets the function called for each log message, instead
+// of Logger. It's called regardless of Debug.
+func (lg *logging) SetLogger(logger func(message string, args ...interface{})) {
+	lg.logger = logger
+}
+
+func (lg *logging) printf(message string, args ...interface{}) {
+	if lg.logger != nil {
+		lg.logger(message, args...)
+		return
+	}
+	printf(message, args...)
+}
+
 var (
 	// ErrKeyExists should be returned when the key has already
 	// been registered with the source and it can't be re-registered
 	// e.g. when using CommandLine, which uses the flag package
 	ErrKeyExists = errors.New("Key already exists")
+	// ErrNoPath should be returned by file based sources when
+	// no path has been configured
+	ErrNoPath = errors.New("No path specified")
+	// ErrNoURL should be returned by remote sources when
+	// no URL has been configured
+	ErrNoURL = errors.New("No URL specified")
+	// ErrNotDirectory should be returned when a path
+	// is expected to be a directory but isn't
+	ErrNotDirectory = errors.New("Not a directory")
+	// ErrUnsupportedValue should be returned when a value can't
+	// be represented as a string, e.g. an object in a JSON file
+	ErrUnsupportedValue = errors.New("Unsupported value type")
 )
 
+// KeySeparator is used to join the keys of nested struct fields
+// for sources which implement NestedSource
+const KeySeparator = "."
+
 // Source should be implemented by Gofigure sources, e.g.
 // environment, command line, file, http etc
 type Source interface {
@@ -40,9 +77,78 @@ type Source interface {
 	Cleanup()
 	// Register is called to register each struct field
 	Register(key, defaultValue string, params map[string]string, t reflect.Type) error
-	// Get is called to retrieve a key value
+	// Get is called to retrieve a key value. If the source has no
+	// value for the key, it must return *overrideDefault unchanged,
+	// or its own default if overrideDefault is nil
 	// - FIXME could use interface{} and maintain types, e.g. json?
 	Get(key string, overrideDefault *string) (string, error)
 	// GetArray is called to retrieve an array value
 	GetArray(key string, overrideDefault *[]string) ([]string, error)
 }
+
+// NestedSource is optionally implemented by sources which can
+// resolve nested struct fields, e.g. structured file formats.
+//
+// If Nested returns true, keys for fields in nested structs are
+// prefixed with the key of their parent field, joined using KeySeparator,
+// e.g. Advanced.MaxBytes
+type NestedSource interface {
+	// Nested is called after Init to check if keys should be nested
+	Nested() bool
+}
+
+// Describer is optionally implemented by sources to describe where
+// the value for a key can be set, e.g. an environment variable name
+type Describer interface {
+	// Describe is called after Register to describe a key
+	Describe(key string) string
+}
+
+// MapSource is optionally implemented by sources which support
+// map fields. Sources which don't implement it are queried using
+// GetArray, with each value in key=value format.
+type MapSource interface {
+	// GetMap is called to retrieve a map value, and should return
+	// an empty map if the source has no value for the key
+	GetMap(key string) (map[string]string, error)
+}
+
+// KeySource is optionally implemented by sources to list the keys
+// of an object or the indexes of an array, which is used for maps
+// and slices of structs
+type KeySource interface {
+	// Keys is called after Init to retrieve the keys for a field,
+	// and should return nil if the source has no value for the key
+	Keys(key string) ([]string, error)
+}
+
+// LookupSource is optionally implemented by sources which can report
+// if they have a value for a key. It's used instead of Get to tell
+// values from defaults.
+type LookupSource interface {
+	// Lookup is called to retrieve a key value, and should
+	// return false if the source has no value for the key
+	Lookup(key string) (string, bool, error)
+}
+
+// LoggerSource is optionally implemented by sources which can
+// log using their own logger instead of Logger, e.g. the logger
+// of a gofigure Loader
+type LoggerSource interface {
+	// SetLogger sets the function called for each log message
+	SetLogger(logger func(message string, args ...interface{}))
+}
+
+// ParseMap parses values in key=value format, e.g. from
+// repeated command line flags
+func ParseMap(values []string) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, v := range values {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || len(strings.TrimSpace(kv[0])) == 0 {
+			return nil, fmt.Errorf("Invalid map value '%s', expected key=value", v)
+		}
+		m[strings.TrimSpace(kv[0])] = kv[1]
+	}
+	return m, nil
+}
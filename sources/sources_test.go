@@ -0,0 +1,50 @@
+package sources
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseMap(t *testing.T) {
+	Convey("ParseMap parses key=value pairs", t, func() {
+		m, err := ParseMap([]string{"a=1", " b = 2", "c=x=y", "d="})
+		So(err, ShouldBeNil)
+		So(m, ShouldResemble, map[string]string{"a": "1", "b": " 2", "c": "x=y", "d": ""})
+
+		m, err = ParseMap(nil)
+		So(err, ShouldBeNil)
+		So(m, ShouldResemble, map[string]string{})
+	})
+
+	Convey("ParseMap returns an error for invalid values", t, func() {
+		_, err := ParseMap([]string{"a=1", "b"})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "Invalid map value 'b', expected key=value")
+
+		_, err = ParseMap([]string{"=1"})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestSetLogger(t *testing.T) {
+	Convey("Sources log using the logger set by SetLogger", t, func() {
+		defer func(debug bool) { Debug = debug }(Debug)
+		Debug = false
+
+		var messages []string
+		env := &Environment{}
+		env.SetLogger(func(message string, args ...interface{}) {
+			messages = append(messages, fmt.Sprintf(message, args...))
+		})
+
+		os.Clearenv()
+		os.Setenv("DB_PASSWORD_FILE", "testdata/secrets/DB_PASSWORD")
+		So(env.Init(map[string]string{"fileSuffix": "_FILE"}), ShouldBeNil)
+		_, err := env.Get("DbPassword", nil)
+		So(err, ShouldBeNil)
+		So(messages, ShouldResemble, []string{"Reading 'DB_PASSWORD' from file 'testdata/secrets/DB_PASSWORD'"})
+	})
+}
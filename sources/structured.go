@@ -0,0 +1,303 @@
+package sources
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// structured implements lookups into decoded structured data,
+// e.g. a JSON document. It is embedded by file based sources.
+type structured struct {
+	logging
+
+	data   map[string]interface{}
+	fields map[string]string
+}
+
+func (s *structured) init(data map[string]interface{}) {
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	s.data = data
+	s.fields = make(map[string]string)
+}
+
+// lookup walks the data using the parts of a nested key. Each part
+// is matched exactly, then case-insensitively, against object keys,
+// or used as an index into arrays. If more than one key matches
+// case-insensitively, the first in sorted order is used.
+func (s *structured) lookup(key string) (interface{}, bool) {
+	var cur interface{} = s.data
+	for _, part := range strings.Split(key, KeySeparator) {
+		switch c := cur.(type) {
+		case map[string]interface{}:
+			v, ok := c[part]
+			if !ok {
+				var matches []string
+				for k := range c {
+					if strings.EqualFold(k, part) {
+						matches = append(matches, k)
+					}
+				}
+				if len(matches) > 0 {
+					sort.Strings(matches)
+					v, ok = c[matches[0]], true
+				}
+			}
+			if !ok {
+				return nil, false
+			}
+			cur = v
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, false
+			}
+			cur = c[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, cur != nil
+}
+
+// normalise converts decoded maps and arrays to map[string]interface{}
+// and []interface{}, e.g. the map[interface{}]interface{} used by YAML
+func normalise(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, mv := range t {
+			m[fmt.Sprintf("%v", k)] = normalise(mv)
+		}
+		return m
+	case map[string]interface{}:
+		for k, mv := range t {
+			t[k] = normalise(mv)
+		}
+		return t
+	case []interface{}:
+		for i, av := range t {
+			t[i] = normalise(av)
+		}
+		return t
+	case []map[string]interface{}:
+		a := make([]interface{}, len(t))
+		for i, av := range t {
+			a[i] = normalise(av)
+		}
+		return a
+	}
+	return v
+}
+
+// setPath sets a value in data using the parts of a nested key,
+// creating intermediate maps as required. If the key already
+// exists, the value is appended to an array.
+func setPath(data map[string]interface{}, parts []string, value interface{}) error {
+	cur := data
+	for i, part := range parts[:len(parts)-1] {
+		v, ok := cur[part]
+		if !ok {
+			m := make(map[string]interface{})
+			cur[part] = m
+			cur = m
+			continue
+		}
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("key '%s' is not a map", strings.Join(parts[:i+1], KeySeparator))
+		}
+		cur = m
+	}
+
+	last := parts[len(parts)-1]
+	switch v := cur[last].(type) {
+	case nil:
+		cur[last] = value
+	case map[string]interface{}:
+		return fmt.Errorf("key '%s' is a map", strings.Join(parts, KeySeparator))
+	case []interface{}:
+		cur[last] = append(v, value)
+	default:
+		cur[last] = []interface{}{v, value}
+	}
+	return nil
+}
+
+// indexedArrays converts maps with keys 0 to n-1 into arrays
+func indexedArrays(v interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	for k, mv := range m {
+		m[k] = indexedArrays(mv)
+	}
+	if len(m) == 0 {
+		return m
+	}
+	arr := make([]interface{}, len(m))
+	for i := range arr {
+		av, ok := m[strconv.Itoa(i)]
+		if !ok {
+			return m
+		}
+		arr[i] = av
+	}
+	return arr
+}
+
+func valueToString(v interface{}) (string, error) {
+	switch t := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return t, nil
+	case bool:
+		return strconv.FormatBool(t), nil
+	case json.Number:
+		return t.String(), nil
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), nil
+	case time.Time:
+		return t.Format(time.RFC3339Nano), nil
+	case map[string]interface{}, []interface{}:
+		return "", ErrUnsupportedValue
+	}
+	return fmt.Sprintf("%v", v), nil
+}
+
+// Nested returns true, structured sources support nested keys
+func (s *structured) Nested() bool {
+	return true
+}
+
+// Register is called to register each struct field
+func (s *structured) Register(key, defaultValue string, params map[string]string, t reflect.Type) error {
+	s.fields[key] = defaultValue
+	return nil
+}
+
+// Lookup is called to retrieve a key value, and returns
+// false if the key isn't in the data
+func (s *structured) Lookup(key string) (string, bool, error) {
+	s.printf("Looking up key '%s'", key)
+	v, ok := s.lookup(key)
+	if !ok {
+		return "", false, nil
+	}
+	val, err := valueToString(v)
+	return val, true, err
+}
+
+// Get is called to retrieve a key value
+func (s *structured) Get(key string, overrideDefault *string) (string, error) {
+	if v, ok, err := s.Lookup(key); ok || err != nil {
+		return v, err
+	}
+	if overrideDefault != nil {
+		return *overrideDefault, nil
+	}
+	return s.fields[key], nil
+}
+
+// Describe returns the key, which is already qualified for nested fields
+func (s *structured) Describe(key string) string {
+	return key
+}
+
+// GetArray is called to retrieve an array value
+func (s *structured) GetArray(key string, overrideDefault *[]string) ([]string, error) {
+	s.printf("Looking up array key '%s'", key)
+	v, ok := s.lookup(key)
+	if !ok {
+		if overrideDefault != nil {
+			return *overrideDefault, nil
+		}
+		return []string{}, nil
+	}
+
+	arr, ok := v.([]interface{})
+	if !ok {
+		arr = []interface{}{v}
+	}
+
+	vals := make([]string, 0, len(arr))
+	for _, a := range arr {
+		str, err := valueToString(a)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, str)
+	}
+	return vals, nil
+}
+
+// GetMap is called to retrieve a map value, from either an
+// object or a string in k1=v1,k2=v2 format
+func (s *structured) GetMap(key string) (map[string]string, error) {
+	s.printf("Looking up map key '%s'", key)
+	v, ok := s.lookup(key)
+	if !ok {
+		return map[string]string{}, nil
+	}
+
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		str, err := valueToString(v)
+		if err != nil {
+			return nil, err
+		}
+		if len(str) == 0 {
+			return map[string]string{}, nil
+		}
+		return ParseMap(strings.Split(str, ","))
+	}
+
+	m := make(map[string]string)
+	for k, e := range obj {
+		str, err := valueToString(e)
+		if err != nil {
+			return nil, err
+		}
+		m[k] = str
+	}
+	return m, nil
+}
+
+// Keys is called to retrieve the keys of an object, in sorted
+// order, or the indexes of an array
+func (s *structured) Keys(key string) ([]string, error) {
+	v, ok := s.lookup(key)
+	if !ok {
+		return nil, nil
+	}
+	if arr, ok := v.([]interface{}); ok {
+		keys := make([]string, 0, len(arr))
+		for i := range arr {
+			keys = append(keys, strconv.Itoa(i))
+		}
+		return keys, nil
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, ErrUnsupportedValue
+	}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Cleanup is called at the end of parsing
+func (s *structured) Cleanup() {
+
+}
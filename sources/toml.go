@@ -0,0 +1,22 @@
+package sources
+
+import "github.com/BurntSushi/toml"
+
+// TOMLFile implements configuration using a TOML file
+type TOMLFile struct {
+	structured
+}
+
+// Init is called at the start of a new struct
+func (t *TOMLFile) Init(args map[string]string) error {
+	return t.initFile(args, "toml")
+}
+
+func decodeTOML(b []byte) (map[string]interface{}, error) {
+	var data map[string]interface{}
+	_, err := toml.Decode(string(b), &data)
+	if err != nil {
+		return nil, err
+	}
+	return normalise(data).(map[string]interface{}), nil
+}
@@ -0,0 +1,68 @@
+package sources
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTOMLFile(t *testing.T) {
+	Convey("Init returns an error without a path", t, func() {
+		tf := &TOMLFile{}
+		So(tf.Init(map[string]string{}), ShouldEqual, ErrNoPath)
+	})
+
+	Convey("TOMLFile reads values from a TOML file", t, func() {
+		tf := &TOMLFile{}
+		So(tf.Init(map[string]string{"path": "testdata/config.toml"}), ShouldBeNil)
+
+		v, err := tf.Get("name", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "gofigure")
+
+		v, err = tf.Get("port", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "8080")
+
+		v, err = tf.Get("ratio", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "0.5")
+
+		v, err = tf.Get("enabled", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "true")
+
+		v, err = tf.Get("started", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "2015-01-02T15:04:05Z")
+
+		v, err = tf.Get("advanced.max_errors", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "10")
+
+		v, err = tf.Get("advanced.deeper.level", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "3")
+
+		v, err = tf.Get("servers.1.host", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "beta")
+	})
+
+	Convey("TOMLFile reads arrays from a TOML file", t, func() {
+		tf := &TOMLFile{}
+		So(tf.Init(map[string]string{"path": "testdata/config.toml"}), ShouldBeNil)
+
+		v, err := tf.GetArray("tags", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldResemble, []string{"a", "b", "c"})
+
+		v, err = tf.GetArray("numbers", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldResemble, []string{"1", "2", "3"})
+
+		v, err = tf.GetArray("advanced.hosts", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldResemble, []string{"localhost"})
+	})
+}
@@ -0,0 +1,22 @@
+package sources
+
+import "gopkg.in/yaml.v2"
+
+// YAMLFile implements configuration using a YAML file
+type YAMLFile struct {
+	structured
+}
+
+// Init is called at the start of a new struct
+func (y *YAMLFile) Init(args map[string]string) error {
+	return y.initFile(args, "yaml")
+}
+
+func decodeYAML(b []byte) (map[string]interface{}, error) {
+	var data map[interface{}]interface{}
+	err := yaml.Unmarshal(b, &data)
+	if err != nil {
+		return nil, err
+	}
+	return normalise(data).(map[string]interface{}), nil
+}
@@ -0,0 +1,65 @@
+package sources
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestYAMLFile(t *testing.T) {
+	Convey("Init returns an error without a path", t, func() {
+		y := &YAMLFile{}
+		So(y.Init(map[string]string{}), ShouldEqual, ErrNoPath)
+	})
+
+	Convey("YAMLFile reads values from a YAML file", t, func() {
+		y := &YAMLFile{}
+		So(y.Init(map[string]string{"path": "testdata/config.yaml"}), ShouldBeNil)
+
+		v, err := y.Get("name", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "gofigure")
+
+		v, err = y.Get("port", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "8080")
+
+		v, err = y.Get("ratio", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "0.5")
+
+		v, err = y.Get("enabled", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "true")
+
+		v, err = y.Get("advanced.max_errors", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "10")
+
+		v, err = y.Get("advanced.deeper.level", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "3")
+
+		od := "override"
+		v, err = y.Get("empty", &od)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "override")
+	})
+
+	Convey("YAMLFile reads sequences from a YAML file", t, func() {
+		y := &YAMLFile{}
+		So(y.Init(map[string]string{"path": "testdata/config.yaml"}), ShouldBeNil)
+
+		v, err := y.GetArray("tags", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldResemble, []string{"a", "b", "c"})
+
+		v, err = y.GetArray("numbers", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldResemble, []string{"1", "2", "3"})
+
+		v, err = y.GetArray("advanced.hosts", nil)
+		So(err, ShouldBeNil)
+		So(v, ShouldResemble, []string{"localhost"})
+	})
+}
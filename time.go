@@ -0,0 +1,68 @@
+package gofigure
+
+import (
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+var timeType = reflect.TypeOf(time.Time{})
+
+// isTimeType returns true for types which are parsed using
+// setTimeType rather than by kind
+func isTimeType(t reflect.Type) bool {
+	return t == durationType || t == timeType
+}
+
+// setTimeType parses val as a time.Duration or time.Time and sets v
+//
+// Durations use Go duration syntax, e.g. 30s or 1h30m, and plain
+// integers are multiplied by the unit tag, e.g. unit:"ms", which
+// defaults to nanoseconds. Times are parsed using the layout tag,
+// which defaults to time.RFC3339.
+func setTimeType(v reflect.Value, val string, keys map[string]string) error {
+	switch v.Type() {
+	case durationType:
+		d, err := parseDuration(val, keys["unit"])
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case timeType:
+		if len(val) == 0 {
+			v.Set(reflect.Zero(timeType))
+			return nil
+		}
+		layout := time.RFC3339
+		if l, ok := keys["layout"]; ok && len(l) > 0 {
+			layout = l
+		}
+		t, err := time.Parse(layout, val)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+	default:
+		return ErrUnsupportedFieldType
+	}
+	return nil
+}
+
+func parseDuration(val, unit string) (time.Duration, error) {
+	if len(val) == 0 {
+		return 0, nil
+	}
+	i, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return time.ParseDuration(val)
+	}
+	if len(unit) == 0 {
+		return time.Duration(i), nil
+	}
+	u, err := time.ParseDuration("1" + unit)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(i) * u, nil
+}
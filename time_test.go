@@ -0,0 +1,143 @@
+package gofigure
+
+import (
+	"flag"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseDuration(t *testing.T) {
+	Convey("parseDuration should parse Go duration syntax", t, func() {
+		d, err := parseDuration("1h30m", "")
+		So(err, ShouldBeNil)
+		So(d, ShouldEqual, 90*time.Minute)
+
+		d, err = parseDuration("", "s")
+		So(err, ShouldBeNil)
+		So(d, ShouldEqual, 0)
+	})
+
+	Convey("parseDuration should use the unit for plain integers", t, func() {
+		d, err := parseDuration("30", "")
+		So(err, ShouldBeNil)
+		So(d, ShouldEqual, 30*time.Nanosecond)
+
+		d, err = parseDuration("30", "s")
+		So(err, ShouldBeNil)
+		So(d, ShouldEqual, 30*time.Second)
+
+		d, err = parseDuration("250", "ms")
+		So(err, ShouldBeNil)
+		So(d, ShouldEqual, 250*time.Millisecond)
+
+		d, err = parseDuration("30s", "ms")
+		So(err, ShouldBeNil)
+		So(d, ShouldEqual, 30*time.Second)
+	})
+
+	Convey("parseDuration should return errors for invalid values", t, func() {
+		_, err := parseDuration("thirty", "")
+		So(err, ShouldNotBeNil)
+
+		_, err = parseDuration("30", "fortnights")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestSetTimeType(t *testing.T) {
+	Convey("setTimeType should parse times using RFC3339 by default", t, func() {
+		var tm time.Time
+		err := setTimeType(reflect.ValueOf(&tm).Elem(), "2016-01-02T15:04:05Z", nil)
+		So(err, ShouldBeNil)
+		So(tm.Equal(time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC)), ShouldBeTrue)
+
+		err = setTimeType(reflect.ValueOf(&tm).Elem(), "02/01/2016", nil)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("setTimeType should use the layout tag", t, func() {
+		var tm time.Time
+		err := setTimeType(reflect.ValueOf(&tm).Elem(), "02/01/2016", map[string]string{"layout": "02/01/2006"})
+		So(err, ShouldBeNil)
+		So(tm.Equal(time.Date(2016, 1, 2, 0, 0, 0, 0, time.UTC)), ShouldBeTrue)
+	})
+}
+
+// MyConfigTime is used to test time.Duration and time.Time support
+type MyConfigTime struct {
+	gofigure interface{}     `order:"env,flag"`
+	Timeout  time.Duration   `env:"TIMEOUT" flag:"timeout" default:"10s"`
+	Interval time.Duration   `env:"INTERVAL" flag:"interval" unit:"ms"`
+	Start    time.Time       `env:"START" flag:"start"`
+	Date     time.Time       `env:"DATE" flag:"date" layout:"2006-01-02"`
+	Backoff  []time.Duration `env:"BACKOFF" flag:"backoff"`
+	Holidays []time.Time     `env:"HOLIDAYS" flag:"holiday" layout:"2006-01-02"`
+	MaxWait  time.Duration   `env:"MAX_WAIT" flag:"max-wait" max:"1m"`
+}
+
+func TestTimeFields(t *testing.T) {
+	Convey("Gofigure should parse time.Duration and time.Time fields", t, func() {
+		os.Clearenv()
+		os.Args = []string{
+			"gofigure",
+			"-interval", "250",
+			"-date", "2016-01-02",
+			"-backoff", "1s",
+			"-backoff", "2s",
+			"-holiday", "2016-12-25",
+		}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Setenv("TIMEOUT", "30s")
+		os.Setenv("START", "2016-01-02T15:04:05Z")
+		var cfg MyConfigTime
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.Timeout, ShouldEqual, 30*time.Second)
+		So(cfg.Interval, ShouldEqual, 250*time.Millisecond)
+		So(cfg.Start.Equal(time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC)), ShouldBeTrue)
+		So(cfg.Date.Equal(time.Date(2016, 1, 2, 0, 0, 0, 0, time.UTC)), ShouldBeTrue)
+		So(cfg.Backoff, ShouldResemble, []time.Duration{time.Second, 2 * time.Second})
+		So(len(cfg.Holidays), ShouldEqual, 1)
+		So(cfg.Holidays[0].Equal(time.Date(2016, 12, 25, 0, 0, 0, 0, time.UTC)), ShouldBeTrue)
+	})
+
+	Convey("Time fields should support defaults and keep existing values", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		start := time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC)
+		cfg := MyConfigTime{Interval: time.Second, Start: start}
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+		So(cfg.Timeout, ShouldEqual, 10*time.Second)
+		So(cfg.Interval, ShouldEqual, time.Second)
+		So(cfg.Start.Equal(start), ShouldBeTrue)
+		So(cfg.Date.IsZero(), ShouldBeTrue)
+		So(len(cfg.Backoff), ShouldEqual, 0)
+	})
+
+	Convey("Invalid durations should return an error", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-timeout", "thirty"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigTime
+		err := Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Duration bounds should use duration syntax", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-max-wait", "2m"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigTime
+		err := Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "Invalid field values: MaxWait: value 2m0s violates max=1m")
+	})
+
+	clear()
+}
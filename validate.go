@@ -0,0 +1,291 @@
+package gofigure
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ValidationError describes a field value which violates a validation tag
+type ValidationError struct {
+	// Path is the fully qualified field name, e.g. Advanced.MaxBytes
+	Path string
+	// Value is the offending value, which may be a slice element
+	Value interface{}
+	// Rule is the violated rule, e.g. max=65535
+	Rule string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: value %v violates %s", e.Path, e.Value, e.Rule)
+}
+
+// ValidationErrors is returned when field values violate validation tags
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	errs := make([]string, 0, len(e))
+	for _, v := range e {
+		errs = append(errs, v.Error())
+	}
+	return "Invalid field values: " + strings.Join(errs, "; ")
+}
+
+// Validator is implemented by structs with cross-field validation rules
+type Validator interface {
+	Validate() error
+}
+
+// StructError wraps an error returned by a Validator
+type StructError struct {
+	// Path is the fully qualified struct name, e.g. Advanced,
+	// or empty for the top level struct
+	Path string
+	Err  error
+}
+
+func (e *StructError) Error() string {
+	if len(e.Path) == 0 {
+		return e.Err.Error()
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap returns the error returned by the Validator
+func (e *StructError) Unwrap() error {
+	return e.Err
+}
+
+// validationTags lists the supported validation tags
+var validationTags = []string{"min", "max", "oneof", "pattern", "len", "minLen", "maxLen"}
+
+// validate checks all fields against their validation tags,
+// returning errors in the order the fields are declared
+func (gfg *gofiguration) validate() error {
+	var errs ValidationErrors
+	err := gfg.validateFields(&errs)
+	if err != nil {
+		return err
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+func (gfg *gofiguration) validateFields(errs *ValidationErrors) error {
+	for _, gfi := range gfg.fields {
+		if gfi.isNil() {
+			continue
+		}
+		if gfi.inner != nil {
+			err := gfi.inner.validateFields(errs)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, tag := range validationTags {
+			rule, ok := gfi.keys[tag]
+			if !ok {
+				continue
+			}
+			err := gfi.validateRule(tag, rule, errs)
+			if err != nil {
+				return fmt.Errorf("Invalid %s tag for field '%s': %s", tag, gfi.path(), err)
+			}
+		}
+
+		for _, e := range gfi.elems {
+			err := e.inner.validateFields(errs)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateRule checks the field against a single validation tag,
+// adding a ValidationError to errs for each violation
+func (gfi *gofiguritem) validateRule(tag, rule string, errs *ValidationErrors) error {
+	fail := func(value interface{}) {
+		*errs = append(*errs, &ValidationError{gfi.path(), value, tag + "=" + rule})
+	}
+
+	v := gfi.goValue
+	switch tag {
+	case "len", "minLen", "maxLen":
+		n, err := strconv.Atoi(rule)
+		if err != nil {
+			return err
+		}
+		var l int
+		switch v.Kind() {
+		case reflect.String:
+			l = utf8.RuneCountInString(v.String())
+		case reflect.Slice, reflect.Array, reflect.Map:
+			l = v.Len()
+		default:
+			return ErrUnsupportedFieldType
+		}
+		if (tag == "len" && l != n) || (tag == "minLen" && l < n) || (tag == "maxLen" && l > n) {
+			fail(v.Interface())
+		}
+		return nil
+	}
+
+	// Other rules apply to scalar values and each slice or array element
+	values := []reflect.Value{v}
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		values = values[:0]
+		for i := 0; i < v.Len(); i++ {
+			values = append(values, v.Index(i))
+		}
+	}
+
+	switch tag {
+	case "min", "max":
+		for _, e := range values {
+			ok, err := checkBound(e, rule, tag == "min")
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fail(e.Interface())
+			}
+		}
+	case "oneof":
+		options := strings.Split(rule, ",")
+		for _, e := range values {
+			s := fmt.Sprintf("%v", e.Interface())
+			found := false
+			for _, o := range options {
+				if s == o {
+					found = true
+					break
+				}
+			}
+			if !found {
+				fail(e.Interface())
+			}
+		}
+	case "pattern":
+		re, err := regexp.Compile(rule)
+		if err != nil {
+			return err
+		}
+		for _, e := range values {
+			if e.Kind() != reflect.String {
+				return ErrUnsupportedFieldType
+			}
+			if !re.MatchString(e.String()) {
+				fail(e.Interface())
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkBound returns false if v is less than a min bound
+// or greater than a max bound
+func checkBound(v reflect.Value, bound string, min bool) (bool, error) {
+	var cmp int
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var b int64
+		var err error
+		if v.Type() == durationType {
+			var d time.Duration
+			d, err = parseDuration(bound, "")
+			b = int64(d)
+		} else {
+			b, err = strconv.ParseInt(bound, 10, 64)
+		}
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case v.Int() < b:
+			cmp = -1
+		case v.Int() > b:
+			cmp = 1
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		b, err := strconv.ParseUint(bound, 10, 64)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case v.Uint() < b:
+			cmp = -1
+		case v.Uint() > b:
+			cmp = 1
+		}
+	case reflect.Float32, reflect.Float64:
+		b, err := strconv.ParseFloat(bound, 64)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case v.Float() < b:
+			cmp = -1
+		case v.Float() > b:
+			cmp = 1
+		}
+	default:
+		return false, ErrUnsupportedFieldType
+	}
+
+	if min {
+		return cmp >= 0, nil
+	}
+	return cmp <= 0, nil
+}
+
+// callValidate calls Validate on nested structs, then on
+// gfg itself if it implements Validator
+func (gfg *gofiguration) callValidate() error {
+	for _, c := range gfg.children {
+		if c.item != nil && c.item.isNil() {
+			continue
+		}
+		err := c.callValidate()
+		if err != nil {
+			return err
+		}
+	}
+
+	var s interface{}
+	switch v := gfg.s.(type) {
+	case reflect.Value:
+		if v.CanAddr() {
+			s = v.Addr().Interface()
+		} else {
+			s = v.Interface()
+		}
+	default:
+		s = v
+	}
+
+	if val, ok := s.(Validator); ok {
+		gfg.printf("Calling Validate")
+		err := val.Validate()
+		if err != nil {
+			path := ""
+			if gfg.item != nil {
+				path = gfg.item.path()
+			}
+			return &StructError{path, err}
+		}
+	}
+
+	return nil
+}
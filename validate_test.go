@@ -0,0 +1,158 @@
+package gofigure
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// MyConfigValidate is used to test validation tags
+type MyConfigValidate struct {
+	gofigure interface{} `order:"env,flag"`
+	Port     int         `env:"PORT" flag:"port" min:"1" max:"65535" default:"8080"`
+	Ratio    float64     `env:"RATIO" flag:"ratio" min:"0" max:"1"`
+	LogLevel string      `env:"LOG_LEVEL" flag:"log-level" oneof:"debug,info,warn" default:"info"`
+	Name     string      `env:"NAME" flag:"name" pattern:"^[a-z]+$" minLen:"2" maxLen:"8" default:"app"`
+	Code     string      `env:"CODE" flag:"code" len:"3" default:"abc"`
+	Workers  []uint      `env:"WORKERS" flag:"worker" max:"10" minLen:"1" default:"1"`
+	Advanced struct {
+		Retries int `env:"RETRIES" flag:"retries" oneof:"0,1,3"`
+	}
+}
+
+// MyConfigBadValidate is used to test invalid validation tags
+type MyConfigBadValidate struct {
+	gofigure interface{}
+	Port     int `min:"abc"`
+}
+
+func TestValidate(t *testing.T) {
+	Convey("Valid values should pass validation", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigValidate
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Invalid values should return ValidationErrors", t, func() {
+		os.Clearenv()
+		os.Args = []string{
+			"gofigure",
+			"-port", "0",
+			"-ratio", "1.5",
+			"-log-level", "trace",
+			"-name", "ABCDEFGHIJ",
+			"-code", "ab",
+			"-worker", "5",
+			"-worker", "11",
+			"-retries", "2",
+		}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigValidate
+		err := Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+		errs, ok := err.(ValidationErrors)
+		So(ok, ShouldBeTrue)
+		So(errs, ShouldResemble, ValidationErrors{
+			{"Port", 0, "min=1"},
+			{"Ratio", 1.5, "max=1"},
+			{"LogLevel", "trace", "oneof=debug,info,warn"},
+			{"Name", "ABCDEFGHIJ", "pattern=^[a-z]+$"},
+			{"Name", "ABCDEFGHIJ", "maxLen=8"},
+			{"Code", "ab", "len=3"},
+			{"Workers", uint(11), "max=10"},
+			{"Advanced.Retries", 2, "oneof=0,1,3"},
+		})
+		So(errs[0].Error(), ShouldEqual, "Port: value 0 violates min=1")
+	})
+
+	Convey("Invalid validation tags should return an error", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigBadValidate
+		err := Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldStartWith, "Invalid min tag for field 'Port'")
+	})
+
+	clear()
+}
+
+type tlsConfig struct {
+	Enabled bool   `env:"TLS_ENABLED" flag:"tls-enabled"`
+	Cert    string `env:"TLS_CERT" flag:"tls-cert"`
+}
+
+func (t *tlsConfig) Validate() error {
+	if t.Enabled && len(t.Cert) == 0 {
+		return errors.New("Cert is required when TLS is enabled")
+	}
+	return nil
+}
+
+// MyConfigValidator is used to test Validate methods
+type MyConfigValidator struct {
+	gofigure interface{} `order:"env,flag"`
+	Name     string      `env:"NAME" flag:"name"`
+	TLS      tlsConfig
+}
+
+var validated []string
+
+var errInvalidName = errors.New("Name is invalid")
+
+func (c *MyConfigValidator) Validate() error {
+	validated = append(validated, "MyConfigValidator")
+	if c.Name == "invalid" {
+		return errInvalidName
+	}
+	return nil
+}
+
+func TestValidator(t *testing.T) {
+	Convey("Validate should be called on nested structs", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-tls-enabled", "true"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigValidator
+		err := Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+		se, ok := err.(*StructError)
+		So(ok, ShouldBeTrue)
+		So(se.Path, ShouldEqual, "TLS")
+		So(err.Error(), ShouldEqual, "TLS: Cert is required when TLS is enabled")
+	})
+
+	Convey("Validate should be called on the top level struct", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-name", "invalid"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		validated = nil
+		var cfg MyConfigValidator
+		err := Gofigure(&cfg)
+		So(err, ShouldNotBeNil)
+		se, ok := err.(*StructError)
+		So(ok, ShouldBeTrue)
+		So(se.Path, ShouldEqual, "")
+		So(err.Error(), ShouldEqual, "Name is invalid")
+		So(errors.Is(err, errInvalidName), ShouldBeTrue)
+		So(validated, ShouldResemble, []string{"MyConfigValidator"})
+	})
+
+	Convey("Valid structs should return no error", t, func() {
+		os.Clearenv()
+		os.Args = []string{"gofigure", "-tls-enabled", "true", "-tls-cert", "cert.pem"}
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		var cfg MyConfigValidator
+		err := Gofigure(&cfg)
+		So(err, ShouldBeNil)
+	})
+
+	clear()
+}